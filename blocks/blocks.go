@@ -19,31 +19,31 @@ package blocks
 
 var (
 	Air                      = Block{ID: 0}
-	Stone                    = Block{ID: 1, LightFiltered: 15, PlacementSound: "dig.stone"}
-	Grass                    = Block{ID: 2, LightFiltered: 15, PlacementSound: "dig.grass"}
-	Dirt                     = Block{ID: 3, LightFiltered: 15, PlacementSound: "dig.gravel"}
-	Cobblestone              = Block{ID: 4, LightFiltered: 15, PlacementSound: "dig.stone"}
-	WoodenPlanks             = Block{ID: 5, LightFiltered: 15, PlacementSound: "dig.wood"}
+	Stone                    = Block{ID: 1, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	Grass                    = Block{ID: 2, LightFiltered: 15, PlacementSound: "dig.grass", Solid: true}
+	Dirt                     = Block{ID: 3, LightFiltered: 15, PlacementSound: "dig.gravel", Solid: true}
+	Cobblestone              = Block{ID: 4, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	WoodenPlanks             = Block{ID: 5, LightFiltered: 15, PlacementSound: "dig.wood", Solid: true}
 	Saplings                 = Block{ID: 6, PlacementSound: "dig.grass"}
-	Bedrock                  = Block{ID: 7, LightFiltered: 15, PlacementSound: "dig.stone"}
+	Bedrock                  = Block{ID: 7, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
 	Water                    = Block{ID: 8, LightFiltered: 2}
 	WaterStill               = Block{ID: 9, LightFiltered: 2}
 	Lava                     = Block{ID: 10, LightEmitted: 15}
 	LavaStill                = Block{ID: 11, LightEmitted: 15}
-	Sand                     = Block{ID: 12, LightFiltered: 15, PlacementSound: "dig.sand"}
-	Gravel                   = Block{ID: 13, LightFiltered: 15, PlacementSound: "dig.gravel"}
-	GoldOre                  = Block{ID: 14, LightFiltered: 15, PlacementSound: "dig.stone"}
-	IronOre                  = Block{ID: 15, LightFiltered: 15, PlacementSound: "dig.stone"}
-	CoalOre                  = Block{ID: 16, LightFiltered: 15, PlacementSound: "dig.stone"}
-	Log                      = Block{ID: 17, LightFiltered: 15, PlacementSound: "dig.wood"}
-	Leaves                   = Block{ID: 18, LightFiltered: 1, PlacementSound: "dig.grass"}
-	Sponge                   = Block{ID: 19, LightFiltered: 15, PlacementSound: "dig.grass"}
-	Glass                    = Block{ID: 20, PlacementSound: "step.stone"}
-	LapisLazuliOre           = Block{ID: 21, LightFiltered: 15, PlacementSound: "dig.stone"}
-	LapisLazuliBlock         = Block{ID: 22, LightFiltered: 15, PlacementSound: "dig.stone"}
-	Dispenser                = Block{ID: 23, LightFiltered: 15, PlacementSound: "dig.stone"}
-	Sandstone                = Block{ID: 24, LightFiltered: 15, PlacementSound: "dig.stone"}
-	NoteBlock                = Block{ID: 25, LightFiltered: 15, PlacementSound: "dig.stone"}
+	Sand                     = Block{ID: 12, LightFiltered: 15, PlacementSound: "dig.sand", Solid: true}
+	Gravel                   = Block{ID: 13, LightFiltered: 15, PlacementSound: "dig.gravel", Solid: true}
+	GoldOre                  = Block{ID: 14, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	IronOre                  = Block{ID: 15, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	CoalOre                  = Block{ID: 16, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	Log                      = Block{ID: 17, LightFiltered: 15, PlacementSound: "dig.wood", Solid: true}
+	Leaves                   = Block{ID: 18, LightFiltered: 1, PlacementSound: "dig.grass", Solid: true}
+	Sponge                   = Block{ID: 19, LightFiltered: 15, PlacementSound: "dig.grass", Solid: true}
+	Glass                    = Block{ID: 20, PlacementSound: "step.stone", Solid: true}
+	LapisLazuliOre           = Block{ID: 21, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	LapisLazuliBlock         = Block{ID: 22, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	Dispenser                = Block{ID: 23, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	Sandstone                = Block{ID: 24, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	NoteBlock                = Block{ID: 25, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
 	Bed                      = Block{ID: 26}
 	PoweredRail              = Block{ID: 27, PlacementSound: "step.stone"}
 	DetectorRail             = Block{ID: 28, PlacementSound: "step.stone"}
@@ -53,34 +53,34 @@ var (
 	DeadBush                 = Block{ID: 32, PlacementSound: "dig.grass"}
 	Piston                   = Block{ID: 33, PlacementSound: "dig.stone"}
 	PistonExtension          = Block{ID: 34}
-	Wool                     = Block{ID: 35, LightFiltered: 15, PlacementSound: "dig.cloth"}
+	Wool                     = Block{ID: 35, LightFiltered: 15, PlacementSound: "dig.cloth", Solid: true}
 	MovedByPiston            = Block{ID: 36}
 	Dandelion                = Block{ID: 37, PlacementSound: "dig.grass"}
 	Poppy                    = Block{ID: 38, PlacementSound: "dig.grass"}
 	BrownMushroom            = Block{ID: 39, PlacementSound: "dig.grass"}
 	RedMushroom              = Block{ID: 40, PlacementSound: "dig.grass"}
-	GoldBlock                = Block{ID: 41, LightFiltered: 15, PlacementSound: "dig.stone"}
-	IronBlock                = Block{ID: 42, LightFiltered: 15, PlacementSound: "dig.stone"}
-	DoubleSlab               = Block{ID: 43, LightFiltered: 15, PlacementSound: "dig.stone"}
+	GoldBlock                = Block{ID: 41, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	IronBlock                = Block{ID: 42, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	DoubleSlab               = Block{ID: 43, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
 	Slab                     = Block{ID: 44, LightFiltered: 1, PlacementSound: "dig.stone"}
-	Bricks                   = Block{ID: 45, LightFiltered: 15, PlacementSound: "dig.stone"}
-	TNT                      = Block{ID: 46, LightFiltered: 15, PlacementSound: "dig.grass"}
-	Bookshelf                = Block{ID: 47, LightFiltered: 15, PlacementSound: "dig.wood"}
-	MossStone                = Block{ID: 48, LightFiltered: 15, PlacementSound: "dig.stone"}
-	Obsidian                 = Block{ID: 49, LightFiltered: 15, PlacementSound: "dig.stone"}
+	Bricks                   = Block{ID: 45, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	TNT                      = Block{ID: 46, LightFiltered: 15, PlacementSound: "dig.grass", Solid: true}
+	Bookshelf                = Block{ID: 47, LightFiltered: 15, PlacementSound: "dig.wood", Solid: true}
+	MossStone                = Block{ID: 48, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	Obsidian                 = Block{ID: 49, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
 	Torch                    = Block{ID: 50, LightEmitted: 14, PlacementSound: "dig.wood"}
 	Fire                     = Block{ID: 51, LightEmitted: 15}
-	MonsterSpawner           = Block{ID: 52}
+	MonsterSpawner           = Block{ID: 52, Solid: true}
 	OakWoodStairs            = Block{ID: 53, LightFiltered: 15, PlacementSound: "dig.wood"}
 	Chest                    = Block{ID: 54, PlacementSound: "dig.wood"}
 	RedstoneWire             = Block{ID: 55}
-	DiamondOre               = Block{ID: 56, LightFiltered: 15, PlacementSound: "dig.stone"}
-	DiamondBlock             = Block{ID: 57, LightFiltered: 15, PlacementSound: "dig.stone"}
-	CraftingTable            = Block{ID: 58, LightFiltered: 15, PlacementSound: "dig.wood"}
+	DiamondOre               = Block{ID: 56, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	DiamondBlock             = Block{ID: 57, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	CraftingTable            = Block{ID: 58, LightFiltered: 15, PlacementSound: "dig.wood", Solid: true}
 	WheatSeeds               = Block{ID: 59, PlacementSound: "dig.grass"}
 	Farmland                 = Block{ID: 60, PlacementSound: "dig.gravel"}
-	Furnace                  = Block{ID: 61, LightFiltered: 15, PlacementSound: "dig.stone"}
-	BurningFurnace           = Block{ID: 62, LightEmitted: 13, PlacementSound: "dig.stone"}
+	Furnace                  = Block{ID: 61, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	BurningFurnace           = Block{ID: 62, LightEmitted: 13, PlacementSound: "dig.stone", Solid: true}
 	SignPost                 = Block{ID: 63, PlacementSound: "dig.wood"}
 	WoodenDoor               = Block{ID: 64, PlacementSound: "dig.wood"}
 	Ladders                  = Block{ID: 65, PlacementSound: "dig.wood"}
@@ -91,46 +91,46 @@ var (
 	StonePressurePlate       = Block{ID: 70, PlacementSound: "dig.stone"}
 	IronDoor                 = Block{ID: 71}
 	WoodenPressurePlate      = Block{ID: 72, PlacementSound: "dig.wood"}
-	RedstoneOre              = Block{ID: 73, LightFiltered: 15, PlacementSound: "dig.stone"}
-	RedstoneOreGlowing       = Block{ID: 74, LightEmitted: 9, PlacementSound: "dig.stone"}
+	RedstoneOre              = Block{ID: 73, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	RedstoneOreGlowing       = Block{ID: 74, LightEmitted: 9, PlacementSound: "dig.stone", Solid: true}
 	RedstoneTorch            = Block{ID: 75, PlacementSound: "dig.wood"}
 	RedstoneTorchActive      = Block{ID: 76, LightEmitted: 7, PlacementSound: "dig.wood"}
 	StoneButton              = Block{ID: 77, PlacementSound: "dig.stone"}
 	Snow                     = Block{ID: 78, PlacementSound: "dig.snow"}
-	Ice                      = Block{ID: 79, LightFiltered: 2, PlacementSound: "step.stone"}
-	SnowBlock                = Block{ID: 80, LightFiltered: 15, PlacementSound: "dig.snow"}
+	Ice                      = Block{ID: 79, LightFiltered: 2, PlacementSound: "step.stone", Solid: true}
+	SnowBlock                = Block{ID: 80, LightFiltered: 15, PlacementSound: "dig.snow", Solid: true}
 	Cactus                   = Block{ID: 81, PlacementSound: "dig.cloth"}
-	ClayBlock                = Block{ID: 82, LightFiltered: 15, PlacementSound: "dig.gravel"}
+	ClayBlock                = Block{ID: 82, LightFiltered: 15, PlacementSound: "dig.gravel", Solid: true}
 	SugarCane                = Block{ID: 83, PlacementSound: "dig.grass"}
-	Jukebox                  = Block{ID: 84, LightFiltered: 15, PlacementSound: "dig.stone"}
+	Jukebox                  = Block{ID: 84, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
 	Fence                    = Block{ID: 85, PlacementSound: "dig.wood"}
-	Pumpkin                  = Block{ID: 86, LightFiltered: 15, PlacementSound: "dig.wood"}
-	Netherrack               = Block{ID: 87, LightFiltered: 15, PlacementSound: "dig.stone"}
+	Pumpkin                  = Block{ID: 86, LightFiltered: 15, PlacementSound: "dig.wood", Solid: true}
+	Netherrack               = Block{ID: 87, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
 	Soulsand                 = Block{ID: 88, LightFiltered: 15, PlacementSound: "dig.sand"}
-	Glowstone                = Block{ID: 89, LightEmitted: 15, PlacementSound: "step.stone"}
+	Glowstone                = Block{ID: 89, LightEmitted: 15, PlacementSound: "step.stone", Solid: true}
 	NetherPortal             = Block{ID: 90, LightEmitted: 11}
-	JackOLantern             = Block{ID: 91, LightEmitted: 15, PlacementSound: "dig.wood"}
+	JackOLantern             = Block{ID: 91, LightEmitted: 15, PlacementSound: "dig.wood", Solid: true}
 	CakeBlock                = Block{ID: 92, PlacementSound: "dig.cloth"}
 	RedstoneRepeater         = Block{ID: 93, PlacementSound: "dig.wood"}
 	RedstoneRepeaterActive   = Block{ID: 94, PlacementSound: "dig.wood"}
-	StainedGlass             = Block{ID: 95, PlacementSound: "step.stone"}
+	StainedGlass             = Block{ID: 95, PlacementSound: "step.stone", Solid: true}
 	Trapdoor                 = Block{ID: 96, PlacementSound: "dig.wood"}
-	MonsterEgg               = Block{ID: 97, LightFiltered: 15, PlacementSound: "dig.stone"}
-	StoneBricks              = Block{ID: 98, LightFiltered: 15, PlacementSound: "dig.stone"}
-	HugeBrownMushroom        = Block{ID: 99, LightFiltered: 15, PlacementSound: "dig.wood"}
-	HugeRedMushroom          = Block{ID: 100, LightFiltered: 15, PlacementSound: "dig.wood"}
+	MonsterEgg               = Block{ID: 97, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	StoneBricks              = Block{ID: 98, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	HugeBrownMushroom        = Block{ID: 99, LightFiltered: 15, PlacementSound: "dig.wood", Solid: true}
+	HugeRedMushroom          = Block{ID: 100, LightFiltered: 15, PlacementSound: "dig.wood", Solid: true}
 	IronBars                 = Block{ID: 101, PlacementSound: "step.stone"}
 	GlassPane                = Block{ID: 102, PlacementSound: "step.stone"}
-	Melon                    = Block{ID: 103, LightFiltered: 15, PlacementSound: "dig.wood"}
+	Melon                    = Block{ID: 103, LightFiltered: 15, PlacementSound: "dig.wood", Solid: true}
 	PumpkinStem              = Block{ID: 104, PlacementSound: "dig.grass"}
 	MelonStem                = Block{ID: 105, PlacementSound: "dig.grass"}
 	Vines                    = Block{ID: 106, PlacementSound: "dig.grass"}
 	FenceGate                = Block{ID: 107, PlacementSound: "dig.wood"}
 	BrickStairs              = Block{ID: 108, LightFiltered: 15, PlacementSound: "dig.stone"}
 	StoneBrickStairs         = Block{ID: 109, LightFiltered: 15, PlacementSound: "dig.stone"}
-	Mycelium                 = Block{ID: 110, LightFiltered: 15, PlacementSound: "dig.grass"}
+	Mycelium                 = Block{ID: 110, LightFiltered: 15, PlacementSound: "dig.grass", Solid: true}
 	Lilypad                  = Block{ID: 111, PlacementSound: "dig.grass"}
-	NetherBrick              = Block{ID: 112, LightFiltered: 15, PlacementSound: "dig.stone"}
+	NetherBrick              = Block{ID: 112, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
 	NetherBrickFence         = Block{ID: 113, PlacementSound: "dig.stone"}
 	NetherBrickStairs        = Block{ID: 114, LightFiltered: 15, PlacementSound: "dig.stone"}
 	NetherWart               = Block{ID: 115, PlacementSound: "dig.grass"}
@@ -139,24 +139,24 @@ var (
 	Cauldron                 = Block{ID: 118, PlacementSound: "dig.stone"}
 	EndPortal                = Block{ID: 119, LightEmitted: 15}
 	EndPortalFrame           = Block{ID: 120, PlacementSound: "dig.stone"}
-	EndStone                 = Block{ID: 121, LightFiltered: 15, PlacementSound: "dig.stone"}
+	EndStone                 = Block{ID: 121, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
 	DragonEgg                = Block{ID: 122}
-	RedstoneLamp             = Block{ID: 123, LightEmitted: 15, PlacementSound: "step.stone"}
-	RedstoneLampActive       = Block{ID: 124, LightFiltered: 15, PlacementSound: "step.stone"}
-	WoodenDoubleSlab         = Block{ID: 125, LightFiltered: 15, PlacementSound: "dig.wood"}
+	RedstoneLamp             = Block{ID: 123, LightEmitted: 15, PlacementSound: "step.stone", Solid: true}
+	RedstoneLampActive       = Block{ID: 124, LightFiltered: 15, PlacementSound: "step.stone", Solid: true}
+	WoodenDoubleSlab         = Block{ID: 125, LightFiltered: 15, PlacementSound: "dig.wood", Solid: true}
 	WoodenSlab               = Block{ID: 126, LightFiltered: 1, PlacementSound: "dig.wood"}
 	CocoaPod                 = Block{ID: 127}
 	SandstoneStairs          = Block{ID: 128, LightFiltered: 15, PlacementSound: "dig.stone"}
-	EmeraldOre               = Block{ID: 129, LightFiltered: 15, PlacementSound: "dig.stone"}
+	EmeraldOre               = Block{ID: 129, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
 	EnderChest               = Block{ID: 130, LightEmitted: 7, PlacementSound: "dig.stone"}
 	TripwireHook             = Block{ID: 131, PlacementSound: "dig.stone"}
 	Tripwire                 = Block{ID: 132, PlacementSound: "dig.stone"}
-	EmeraldBlock             = Block{ID: 133, LightFiltered: 15, PlacementSound: "dig.stone"}
+	EmeraldBlock             = Block{ID: 133, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
 	SpruceWoodStairs         = Block{ID: 134, LightFiltered: 15, PlacementSound: "dig.wood"}
 	BirchWoodStairs          = Block{ID: 135, LightFiltered: 15, PlacementSound: "dig.wood"}
 	JungleWoodStairs         = Block{ID: 136, LightFiltered: 15, PlacementSound: "dig.wood"}
-	CommandBlock             = Block{ID: 137, LightFiltered: 15, PlacementSound: "dig.stone"}
-	Beacon                   = Block{ID: 138, LightEmitted: 15, PlacementSound: "dig.stone"}
+	CommandBlock             = Block{ID: 137, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	Beacon                   = Block{ID: 138, LightEmitted: 15, PlacementSound: "dig.stone", Solid: true}
 	CobblestoneWall          = Block{ID: 139, PlacementSound: "dig.stone"}
 	FlowerPot                = Block{ID: 140, PlacementSound: "dig.stone"}
 	Carrots                  = Block{ID: 141, PlacementSound: "dig.grass"}
@@ -164,30 +164,30 @@ var (
 	WoodenButton             = Block{ID: 143, PlacementSound: "dig.wood"}
 	Head                     = Block{ID: 144, PlacementSound: "dig.stone"}
 	Anvil                    = Block{ID: 145, PlacementSound: "random.anvil.land"}
-	TrappedChest             = Block{ID: 146, LightFiltered: 15, PlacementSound: "dig.wood"}
+	TrappedChest             = Block{ID: 146, LightFiltered: 15, PlacementSound: "dig.wood", Solid: true}
 	GoldPressurePlate        = Block{ID: 147, PlacementSound: "dig.stone"}
 	IronPressurePlate        = Block{ID: 148, PlacementSound: "dig.stone"}
 	RedstoneComparator       = Block{ID: 149, PlacementSound: "dig.wood"}
 	RedstoneComparatorActive = Block{ID: 150, LightEmitted: 9, PlacementSound: "dig.wood"}
 	DaylightSensor           = Block{ID: 151, PlacementSound: "dig.wood"}
-	RedstoneBlock            = Block{ID: 152, LightFiltered: 15, PlacementSound: "dig.stone"}
-	NetherQuartzOre          = Block{ID: 153, LightFiltered: 15, PlacementSound: "dig.stone"}
+	RedstoneBlock            = Block{ID: 152, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	NetherQuartzOre          = Block{ID: 153, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
 	Hopper                   = Block{ID: 154, PlacementSound: "dig.stone"}
-	QuartzBlock              = Block{ID: 155, LightFiltered: 15, PlacementSound: "dig.stone"}
+	QuartzBlock              = Block{ID: 155, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
 	QuartzStairs             = Block{ID: 156, LightFiltered: 15, PlacementSound: "dig.stone"}
 	ActivatorRail            = Block{ID: 157, PlacementSound: "step.stone"}
-	Dropper                  = Block{ID: 158, LightFiltered: 15, PlacementSound: "dig.stone"}
-	StainedClay              = Block{ID: 159, LightFiltered: 15, PlacementSound: "dig.stone"}
+	Dropper                  = Block{ID: 158, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	StainedClay              = Block{ID: 159, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
 	StainedGlassPane         = Block{ID: 160, PlacementSound: "step.stone"}
-	Leaves2                  = Block{ID: 161, LightFiltered: 1, PlacementSound: "dig.grass"}
-	Log2                     = Block{ID: 162, LightFiltered: 15, PlacementSound: "dig.wood"}
+	Leaves2                  = Block{ID: 161, LightFiltered: 1, PlacementSound: "dig.grass", Solid: true}
+	Log2                     = Block{ID: 162, LightFiltered: 15, PlacementSound: "dig.wood", Solid: true}
 	AcaciaWoodStairs         = Block{ID: 163, LightFiltered: 15, PlacementSound: "dig.wood"}
 	DarkOakWoodStairs        = Block{ID: 164, LightFiltered: 15, PlacementSound: "dig.wood"}
-	HayBlock                 = Block{ID: 170, LightFiltered: 15, PlacementSound: "dig.grass"}
+	HayBlock                 = Block{ID: 170, LightFiltered: 15, PlacementSound: "dig.grass", Solid: true}
 	Carpet                   = Block{ID: 171, PlacementSound: "dig.cloth"}
-	HardenedClay             = Block{ID: 172, LightFiltered: 15, PlacementSound: "dig.stone"}
-	CoalBlock                = Block{ID: 173, LightFiltered: 15, PlacementSound: "dig.stone"}
-	PackedIce                = Block{ID: 174, LightFiltered: 15, PlacementSound: "step.stone"}
+	HardenedClay             = Block{ID: 172, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	CoalBlock                = Block{ID: 173, LightFiltered: 15, PlacementSound: "dig.stone", Solid: true}
+	PackedIce                = Block{ID: 174, LightFiltered: 15, PlacementSound: "step.stone", Solid: true}
 	LargeFlowers             = Block{ID: 175, PlacementSound: "dig.grass"}
 )
 
@@ -197,5 +197,6 @@ type Block struct {
 	LightEmitted   byte
 	LightFiltered  byte
 	PlacementSound string
-	Solid          bool
+	//Whether the block is a full cube entities can't move through
+	Solid bool
 }
@@ -0,0 +1,383 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+/*
+	Package client is a minimal minecraft client that can connect to a
+	Netherrack (or offline mode vanilla) server. It handles logging in,
+	keep alives and chunk tracking so it can be used for end-to-end tests
+	and bots without a real minecraft client.
+*/
+package client
+
+import (
+	"errors"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrorClosed = errors.New("Client closed")
+
+//Height of the player's eyes above their feet
+const eyeHeight = 1.62
+
+//Handler is called for every packet received from the server after the
+//client has processed it. It is called on the client's read goroutine so
+//it should not block for long.
+type Handler func(c *Client, packet protocol.Packet)
+
+//Client is a connection to a server as a player
+type Client struct {
+	Username string
+	UUID     string
+
+	conn    *protocol.Conn
+	netConn net.Conn
+	handler Handler
+
+	packetQueue   chan protocol.Packet
+	ClosedChannel chan struct{}
+	closeOnce     sync.Once
+	err           error
+
+	spawned     chan struct{}
+	spawnedOnce sync.Once
+
+	entityID  int32
+	dimension int8
+
+	packetsRead    uint64
+	packetsWritten uint64
+
+	position struct {
+		sync.Mutex
+		X, Y, Z    float64
+		Yaw, Pitch float32
+		OnGround   bool
+		dirty      bool
+	}
+
+	chunks struct {
+		sync.RWMutex
+		m map[uint64]struct{}
+	}
+}
+
+//Dial connects to the server at the address and logs in with the
+//username. The handler may be nil.
+func Dial(address, username string, handler Handler) (*Client, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	mcConn := &protocol.Conn{
+		Out:            conn,
+		In:             conn,
+		Deadliner:      conn,
+		ReadDirection:  protocol.Clientbound,
+		WriteDirection: protocol.Serverbound,
+	}
+	uuid, err := mcConn.ClientLogin(host, uint16(port), username)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c := &Client{
+		Username:      username,
+		UUID:          uuid,
+		conn:          mcConn,
+		netConn:       conn,
+		handler:       handler,
+		packetQueue:   make(chan protocol.Packet, 200),
+		ClosedChannel: make(chan struct{}),
+		spawned:       make(chan struct{}),
+	}
+	c.position.OnGround = true
+	c.chunks.m = make(map[uint64]struct{})
+	go c.packetReader()
+	go c.packetWriter()
+	go c.ticker()
+	return c, nil
+}
+
+//Closes the connection to the server
+func (c *Client) Close() {
+	c.close(ErrorClosed)
+}
+
+func (c *Client) close(err error) {
+	c.closeOnce.Do(func() {
+		c.err = err
+		close(c.ClosedChannel)
+		c.netConn.Close()
+	})
+}
+
+//Returns the reason the client closed. Only valid once ClosedChannel
+//is closed
+func (c *Client) Err() error {
+	return c.err
+}
+
+//Returns a channel that is closed once the server has positioned the
+//player for the first time
+func (c *Client) Spawned() <-chan struct{} {
+	return c.spawned
+}
+
+//Returns the entity id the server gave the client's player
+func (c *Client) EntityID() int32 {
+	c.position.Lock()
+	defer c.position.Unlock()
+	return c.entityID
+}
+
+//Returns the number of packets read from and written to the server
+func (c *Client) PacketCount() (read, written uint64) {
+	return atomic.LoadUint64(&c.packetsRead), atomic.LoadUint64(&c.packetsWritten)
+}
+
+//Queues a packet to be sent to the server
+func (c *Client) QueuePacket(packet protocol.Packet) {
+	select {
+	case c.packetQueue <- packet:
+	case <-c.ClosedChannel:
+	}
+}
+
+//Sends a chat message (or command) to the server
+func (c *Client) Chat(msg string) {
+	c.QueuePacket(protocol.ChatMessage{Message: msg})
+}
+
+//Returns the client's current position
+func (c *Client) Position() (x, y, z float64) {
+	c.position.Lock()
+	defer c.position.Unlock()
+	return c.position.X, c.position.Y, c.position.Z
+}
+
+//Moves the client to the position. The movement will be sent to the
+//server on the next tick.
+func (c *Client) Move(x, y, z float64) {
+	c.position.Lock()
+	defer c.position.Unlock()
+	c.position.X, c.position.Y, c.position.Z = x, y, z
+	c.position.dirty = true
+}
+
+//Moves the client by the passed amounts
+func (c *Client) MoveBy(dx, dy, dz float64) {
+	c.position.Lock()
+	defer c.position.Unlock()
+	c.position.X += dx
+	c.position.Y += dy
+	c.position.Z += dz
+	c.position.dirty = true
+}
+
+//Changes the direction the client is looking
+func (c *Client) Look(yaw, pitch float32) {
+	c.position.Lock()
+	defer c.position.Unlock()
+	c.position.Yaw, c.position.Pitch = yaw, pitch
+	c.position.dirty = true
+}
+
+//Returns whether the chunk at the coordinates has been sent to the client
+func (c *Client) Chunk(x, z int) bool {
+	c.chunks.RLock()
+	defer c.chunks.RUnlock()
+	_, ok := c.chunks.m[chunkKey(x, z)]
+	return ok
+}
+
+//Returns the number of chunks the client currently has loaded
+func (c *Client) ChunkCount() int {
+	c.chunks.RLock()
+	defer c.chunks.RUnlock()
+	return len(c.chunks.m)
+}
+
+//Places the held item against the block at the coordinates
+func (c *Client) PlaceBlock(x, y, z int, face int8, item protocol.Slot) {
+	c.QueuePacket(protocol.PlayerBlockPlacement{
+		X:         int32(x),
+		Y:         byte(y),
+		Z:         int32(z),
+		Direction: face,
+		HeldItem:  item,
+	})
+}
+
+//Instantly breaks the block at the coordinates
+func (c *Client) DigBlock(x, y, z int, face byte) {
+	c.QueuePacket(protocol.PlayerDigging{
+		Status: 0,
+		X:      int32(x),
+		Y:      byte(y),
+		Z:      int32(z),
+		Face:   face,
+	})
+	c.QueuePacket(protocol.PlayerDigging{
+		Status: 2,
+		X:      int32(x),
+		Y:      byte(y),
+		Z:      int32(z),
+		Face:   face,
+	})
+}
+
+//Acts on the passed packet
+func (c *Client) processPacket(packet protocol.Packet) {
+	switch packet := packet.(type) {
+	case protocol.KeepAlive:
+		c.QueuePacket(protocol.ClientKeepAlive{KeepAliveID: packet.KeepAliveID})
+	case protocol.JoinGame:
+		c.position.Lock()
+		c.entityID = packet.EntityID
+		c.dimension = packet.Dimension
+		c.position.Unlock()
+	case protocol.Respawn:
+		//Chunks are only unloaded when changing dimension
+		c.position.Lock()
+		changed := int32(c.dimension) != packet.Dimension
+		c.dimension = int8(packet.Dimension)
+		c.position.Unlock()
+		if changed {
+			c.chunks.Lock()
+			c.chunks.m = make(map[uint64]struct{})
+			c.chunks.Unlock()
+		}
+	case protocol.PlayerPositionLook:
+		//The server sends the height of the player's eyes
+		feet := packet.Y - eyeHeight
+		c.position.Lock()
+		c.position.X, c.position.Y, c.position.Z = packet.X, feet, packet.Z
+		c.position.Yaw, c.position.Pitch = packet.Yaw, packet.Pitch
+		c.position.OnGround = packet.OnGround
+		c.position.dirty = false
+		c.position.Unlock()
+		//The server expects the position to be confirmed
+		c.QueuePacket(protocol.ClientPlayerPositionLook{
+			X:        packet.X,
+			Y:        feet,
+			Stance:   packet.Y,
+			Z:        packet.Z,
+			Yaw:      packet.Yaw,
+			Pitch:    packet.Pitch,
+			OnGround: packet.OnGround,
+		})
+		c.spawnedOnce.Do(func() { close(c.spawned) })
+	case protocol.ChunkData:
+		c.chunks.Lock()
+		if packet.GroundUp && packet.PrimaryBitMap == 0 {
+			delete(c.chunks.m, chunkKey(int(packet.X), int(packet.Z)))
+		} else {
+			c.chunks.m[chunkKey(int(packet.X), int(packet.Z))] = struct{}{}
+		}
+		c.chunks.Unlock()
+	case protocol.MapChunkBulk:
+		c.chunks.Lock()
+		for _, meta := range packet.Meta {
+			c.chunks.m[chunkKey(int(meta.X), int(meta.Z))] = struct{}{}
+		}
+		c.chunks.Unlock()
+	case protocol.Disconnect:
+		c.close(errors.New(packet.Reason))
+	}
+}
+
+//Sends the client's position to the server every tick like the vanilla
+//client does. Servers will time the client out without this.
+func (c *Client) ticker() {
+	tick := time.NewTicker(time.Second / 20)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			c.position.Lock()
+			packet := protocol.ClientPlayerPositionLook{
+				X:        c.position.X,
+				Y:        c.position.Y,
+				Stance:   c.position.Y + eyeHeight,
+				Z:        c.position.Z,
+				Yaw:      float32(math.Mod(float64(c.position.Yaw), 360)),
+				Pitch:    c.position.Pitch,
+				OnGround: c.position.OnGround,
+			}
+			dirty := c.position.dirty
+			c.position.dirty = false
+			c.position.Unlock()
+			if dirty {
+				c.QueuePacket(packet)
+			} else {
+				c.QueuePacket(protocol.ClientPlayer{OnGround: packet.OnGround})
+			}
+		case <-c.ClosedChannel:
+			return
+		}
+	}
+}
+
+//Reads incomming packets and processes them
+func (c *Client) packetReader() {
+	for {
+		packet, err := c.conn.ReadPacket()
+		if err != nil {
+			c.close(err)
+			return
+		}
+		atomic.AddUint64(&c.packetsRead, 1)
+		c.processPacket(packet)
+		if c.handler != nil {
+			c.handler(c, packet)
+		}
+	}
+}
+
+func (c *Client) packetWriter() {
+	for {
+		select {
+		case packet := <-c.packetQueue:
+			if err := c.conn.WritePacket(packet); err != nil {
+				c.close(err)
+				return
+			}
+			atomic.AddUint64(&c.packetsWritten, 1)
+		case <-c.ClosedChannel:
+			return
+		}
+	}
+}
+
+func chunkKey(x, z int) uint64 {
+	return (uint64(int32(x)) & 0xFFFFFFFF) | ((uint64(int32(z)) & 0xFFFFFFFF) << 32)
+}
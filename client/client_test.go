@@ -0,0 +1,1002 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"compress/zlib"
+	"github.com/NetherrackDev/netherrack"
+	"github.com/NetherrackDev/netherrack/entity/generic"
+	"github.com/NetherrackDev/netherrack/entity/player"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"github.com/NetherrackDev/netherrack/world"
+	"github.com/NetherrackDev/netherrack/world/flat"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testServer struct{}
+
+func (testServer) PlayerJoin(p *player.Player) (bool, string) {
+	p.Handler = testPlayer{p}
+	switch p.Username {
+	case "banned":
+		return true, "You are banned"
+	case "builder":
+		p.SetGameMode(player.Creative)
+	case "adventurer":
+		p.SetGameMode(player.Adventure)
+	case "walker", "faller", "nofaller", "saver", "runner", "sprinter", "drifter", "hoarder":
+		//These tests move further than a real client could
+		p.SetMovementChecks(false)
+	}
+	return false, ""
+}
+
+type testPlayer struct {
+	p *player.Player
+}
+
+func (testPlayer) EnterWorld(*protocol.JoinGame)                {}
+func (testPlayer) BlockPlacement(protocol.PlayerBlockPlacement) {}
+func (testPlayer) BlockDig(protocol.PlayerDigging)              {}
+func (testPlayer) Leave()                                       {}
+
+//Violations committed by the player named cheater
+var violations = make(chan player.Violation, 10)
+
+func (tp testPlayer) IllegalMove(v player.Violation, count int) {
+	if tp.p.Username == "cheater" {
+		violations <- v
+	}
+}
+
+func (tp testPlayer) Chat(msg string) {
+	switch msg {
+	case "nether":
+		tp.p.Teleport(tp.p.Server.World("nether"), 8, 70, 8, 90, 0)
+	case "give":
+		tp.p.GiveItem(protocol.Slot{ID: 1, Count: 5})
+	case "item":
+		generic.Spawn(generic.NewItem(protocol.Slot{ID: 1, Count: 3}), tp.p.World, tp.p.X+2, tp.p.Y+2, tp.p.Z)
+	case "zombie":
+		generic.Spawn(generic.NewMob(generic.MobZombie), tp.p.World, tp.p.X-2, tp.p.Y, tp.p.Z)
+	case "stash":
+		//Far enough away that nobody is watching the chunk
+		generic.Spawn(generic.NewItem(protocol.Slot{ID: 4, Count: 1}), tp.p.World, 40*16+8, tp.p.Y, 8)
+	}
+}
+
+var serverAddress string
+
+func TestMain(m *testing.M) {
+	//Worlds are saved relative to the working directory
+	dir, err := ioutil.TempDir("", "netherrack-client")
+	if err != nil {
+		panic(err)
+	}
+	os.Chdir(dir)
+
+	server := netherrack.NewServer()
+	server.Handler = testServer{}
+	server.SetAuthenticator(nil)
+	server.LoadWorld("test", &world.MsgpackSystem{}, flat.ClassicFlat, world.Overworld)
+	server.LoadWorld("nether", &world.MsgpackSystem{}, flat.ClassicFlat, world.Nether)
+	server.SetDefaultWorld("test")
+
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	serverAddress = listen.Addr().String()
+	go server.Serve(listen)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func waitFor(t *testing.T, c *Client, what string, cond func() bool) {
+	timeout := time.After(10 * time.Second)
+	for !cond() {
+		select {
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %s", what)
+		case <-c.ClosedChannel:
+			t.Fatalf("Client closed waiting for %s: %s", what, c.Err())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestLogin(t *testing.T) {
+	c, err := Dial(serverAddress, "tester", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.UUID == "" {
+		t.Fatal("No uuid returned")
+	}
+	select {
+	case <-c.Spawned():
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting to spawn")
+	}
+	waitFor(t, c, "spawn chunks", func() bool { return c.ChunkCount() == 21*21 })
+}
+
+func TestJoinRejected(t *testing.T) {
+	c, err := Dial(serverAddress, "banned", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	select {
+	case <-c.ClosedChannel:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting to be disconnected")
+	}
+	if c.Err() == nil || c.Err().Error() != "You are banned" {
+		t.Fatalf("Expected the ban reason, got %v", c.Err())
+	}
+}
+
+func TestDuplicateLogin(t *testing.T) {
+	first, err := Dial(serverAddress, "twin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	<-first.Spawned()
+
+	second, err := Dial(serverAddress, "twin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	select {
+	case <-first.ClosedChannel:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for the first session to be kicked")
+	}
+	if first.Err() == nil || first.Err().Error() != "You logged in from another location" {
+		t.Fatalf("Expected to be kicked, got %v", first.Err())
+	}
+	select {
+	case <-second.Spawned():
+	case <-second.ClosedChannel:
+		t.Fatalf("Second session closed: %s", second.Err())
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for the second session to spawn")
+	}
+}
+
+func TestMoveLoadsChunks(t *testing.T) {
+	c, err := Dial(serverAddress, "walker", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	<-c.Spawned()
+	waitFor(t, c, "spawn chunks", func() bool { return c.Chunk(0, 0) })
+	if c.Chunk(13, 0) {
+		t.Fatal("Chunk outside of view distance loaded")
+	}
+	_, y, _ := c.Position()
+	c.Move(16*3+8, y, 8)
+	waitFor(t, c, "new chunks", func() bool { return c.Chunk(13, 0) })
+	waitFor(t, c, "old chunks to unload", func() bool { return !c.Chunk(-8, 0) })
+}
+
+func TestPlayerList(t *testing.T) {
+	var lock sync.Mutex
+	list := map[string]bool{}
+	a, err := Dial(serverAddress, "lister", func(c *Client, packet protocol.Packet) {
+		if item, ok := packet.(protocol.PlayerListItem); ok {
+			lock.Lock()
+			list[item.PlayerName] = item.Online
+			lock.Unlock()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	listed := func(name string) bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return list[name]
+	}
+	waitFor(t, a, "own entry", func() bool { return listed("lister") })
+
+	b, err := Dial(serverAddress, "listed", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, a, "other player's entry", func() bool { return listed("listed") })
+	b.Close()
+	waitFor(t, a, "other player's entry to be removed", func() bool { return !listed("listed") })
+}
+
+func TestGameMode(t *testing.T) {
+	var lock sync.Mutex
+	var gamemode, abilities byte
+	c, err := Dial(serverAddress, "builder", func(c *Client, packet protocol.Packet) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch packet := packet.(type) {
+		case protocol.JoinGame:
+			gamemode = packet.Gamemode
+		case protocol.PlayerAbilities:
+			abilities = packet.Flags
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	waitFor(t, c, "abilities", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return abilities != 0
+	})
+	lock.Lock()
+	defer lock.Unlock()
+	if gamemode != byte(player.Creative) {
+		t.Fatalf("Joined with game mode %d", gamemode)
+	}
+	if abilities&0x8 == 0 {
+		t.Fatalf("Creative player can't instantly build: %x", abilities)
+	}
+}
+
+func TestAdventurePlacementUndone(t *testing.T) {
+	var lock sync.Mutex
+	var changes []protocol.BlockChange
+	c, err := Dial(serverAddress, "adventurer", func(c *Client, packet protocol.Packet) {
+		if packet, ok := packet.(protocol.BlockChange); ok {
+			lock.Lock()
+			changes = append(changes, packet)
+			lock.Unlock()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	<-c.Spawned()
+	fx, fy, fz := c.Position()
+	x, y, z := int(math.Floor(fx)), int(math.Floor(fy)), int(math.Floor(fz))
+	//On top of the block the player is standing on
+	c.PlaceBlock(x, y-1, z, 1, protocol.Slot{ID: 1, Count: 1})
+	waitFor(t, c, "placed block to be undone", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, change := range changes {
+			if change.X == int32(x) && int(change.Y) == y && change.Z == int32(z) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func TestDeathAndRespawn(t *testing.T) {
+	var lock sync.Mutex
+	var health float32 = -1
+	respawned := false
+	c, err := Dial(serverAddress, "faller", func(c *Client, packet protocol.Packet) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch packet := packet.(type) {
+		case protocol.UpdateHealth:
+			health = packet.Health
+			if health <= 0 {
+				c.QueuePacket(protocol.ClientStatuses{Payload: 0})
+			}
+		case protocol.Respawn:
+			respawned = true
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	<-c.Spawned()
+	waitFor(t, c, "full health", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return health == 20
+	})
+	x, y, z := c.Position()
+	//Landing after falling far out of the world
+	c.Move(x, -100, z)
+	waitFor(t, c, "respawn", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return respawned && health == 20
+	})
+	waitFor(t, c, "move to spawn", func() bool {
+		_, ry, _ := c.Position()
+		return ry == y
+	})
+}
+
+func TestNoFall(t *testing.T) {
+	var lock sync.Mutex
+	var health float32 = -1
+	c, err := Dial(serverAddress, "nofaller", func(c *Client, packet protocol.Packet) {
+		if update, ok := packet.(protocol.UpdateHealth); ok {
+			lock.Lock()
+			health = update.Health
+			lock.Unlock()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	<-c.Spawned()
+	waitFor(t, c, "full health", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return health == 20
+	})
+	//Claim to never touch the ground
+	c.position.Lock()
+	c.position.OnGround = false
+	c.position.Unlock()
+	x, y, z := c.Position()
+	c.Move(x, y+10, z)
+	time.Sleep(500 * time.Millisecond)
+	c.Move(x, y, z)
+	waitFor(t, c, "fall damage", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return health < 20
+	})
+}
+
+func TestTeleportBetweenWorlds(t *testing.T) {
+	var lock sync.Mutex
+	var dimension int32
+	c, err := Dial(serverAddress, "traveller", func(c *Client, packet protocol.Packet) {
+		if respawn, ok := packet.(protocol.Respawn); ok {
+			lock.Lock()
+			dimension = respawn.Dimension
+			lock.Unlock()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	<-c.Spawned()
+	waitFor(t, c, "spawn chunks", func() bool { return c.ChunkCount() == 21*21 })
+	c.Chat("nether")
+	waitFor(t, c, "respawn in the nether", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return dimension == int32(world.Nether)
+	})
+	waitFor(t, c, "teleport", func() bool {
+		x, y, z := c.Position()
+		return x == 8 && y == 70 && z == 8
+	})
+	waitFor(t, c, "nether chunks", func() bool { return c.ChunkCount() == 21*21 })
+}
+
+func TestSpawnPosition(t *testing.T) {
+	var lock sync.Mutex
+	var spawn *protocol.SpawnPosition
+	c, err := Dial(serverAddress, "spawner", func(c *Client, packet protocol.Packet) {
+		if packet, ok := packet.(protocol.SpawnPosition); ok {
+			lock.Lock()
+			spawn = &packet
+			lock.Unlock()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	<-c.Spawned()
+	lock.Lock()
+	defer lock.Unlock()
+	if spawn == nil {
+		t.Fatal("Spawn position not sent before the player spawned")
+	}
+	//The classic flat world's surface is at y 3
+	x, y, z := c.Position()
+	if x != float64(spawn.X)+0.5 || y != float64(spawn.Y) || z != float64(spawn.Z)+0.5 || y != 4 {
+		t.Fatalf("Spawned at %f, %f, %f with spawn %+v", x, y, z, *spawn)
+	}
+}
+
+func TestPlayerDataSaved(t *testing.T) {
+	var lock sync.Mutex
+	online := map[string]bool{}
+	watcher, err := Dial(serverAddress, "observer", func(c *Client, packet protocol.Packet) {
+		if item, ok := packet.(protocol.PlayerListItem); ok {
+			lock.Lock()
+			online[item.PlayerName] = item.Online
+			lock.Unlock()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+
+	var items []protocol.Slot
+	given := false
+	c, err := Dial(serverAddress, "saver", func(c *Client, packet protocol.Packet) {
+		if packet, ok := packet.(protocol.WindowSetSlot); ok && packet.Item.ID == 1 {
+			lock.Lock()
+			given = true
+			lock.Unlock()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-c.Spawned()
+	c.Move(20.5, 4, 20.5)
+	c.QueuePacket(protocol.ClientPlayerPosition{X: 20.5, Y: 4, Stance: 5.62, Z: 20.5, OnGround: true})
+	//Packets are handled in order so the move has been seen once the
+	//item arrives
+	c.Chat("give")
+	waitFor(t, c, "item", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return given
+	})
+	waitFor(t, watcher, "player to join", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return online["saver"]
+	})
+	c.Close()
+	//Players are removed from the list after they are saved
+	waitFor(t, watcher, "player to leave", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return !online["saver"]
+	})
+
+	c, err = Dial(serverAddress, "saver", func(c *Client, packet protocol.Packet) {
+		if packet, ok := packet.(protocol.WindowItems); ok && packet.WindowID == 0 {
+			lock.Lock()
+			items = packet.Slots
+			lock.Unlock()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	<-c.Spawned()
+	if x, y, z := c.Position(); x != 20.5 || y != 4 || z != 20.5 {
+		t.Fatalf("Rejoined at %f, %f, %f", x, y, z)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	found := false
+	for _, item := range items {
+		if item.ID == 1 && item.Count == 5 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Inventory not restored: %v", items)
+	}
+}
+
+func TestViewDistance(t *testing.T) {
+	c, err := Dial(serverAddress, "shortsighted", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	<-c.Spawned()
+	waitFor(t, c, "spawn chunks", func() bool { return c.ChunkCount() == 21*21 })
+	c.QueuePacket(protocol.ClientSettings{Locale: "en_GB", ViewDistance: 4})
+	waitFor(t, c, "chunks to unload", func() bool { return c.ChunkCount() == 9*9 })
+	//The server's distance limits larger requests
+	c.QueuePacket(protocol.ClientSettings{Locale: "en_GB", ViewDistance: 16})
+	waitFor(t, c, "chunks to load", func() bool { return c.ChunkCount() == 21*21 })
+}
+
+func TestChunksNearestFirst(t *testing.T) {
+	var lock sync.Mutex
+	var order [][2]int32
+	c, err := Dial(serverAddress, "impatient", func(c *Client, packet protocol.Packet) {
+		if bulk, ok := packet.(protocol.MapChunkBulk); ok {
+			lock.Lock()
+			for _, meta := range bulk.Meta {
+				order = append(order, [2]int32{meta.X, meta.Z})
+			}
+			lock.Unlock()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	waitFor(t, c, "first chunks", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(order) >= 9
+	})
+	lock.Lock()
+	defer lock.Unlock()
+	//The player spawns in chunk 0, 0
+	for _, pos := range order[:9] {
+		if pos[0] < -2 || pos[0] > 2 || pos[1] < -2 || pos[1] > 2 {
+			t.Fatalf("Distant chunk sent first: %v", order[:9])
+		}
+	}
+}
+
+func TestChunksBatched(t *testing.T) {
+	bulks := make(chan protocol.MapChunkBulk, 100)
+	c, err := Dial(serverAddress, "bulky", func(c *Client, packet protocol.Packet) {
+		if bulk, ok := packet.(protocol.MapChunkBulk); ok {
+			select {
+			case bulks <- bulk:
+			default:
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	var bulk protocol.MapChunkBulk
+	select {
+	case bulk = <-bulks:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for MapChunkBulk")
+	}
+	if bulk.SkyLight != 1 || int(bulk.ChunkCount) != len(bulk.Meta) || bulk.ChunkCount > 10 {
+		t.Fatalf("Bad MapChunkBulk: %d chunks, sky light %d", bulk.ChunkCount, bulk.SkyLight)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(bulk.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//Blocks, data, block light and sky light for each section plus biomes
+	expected := 0
+	for _, meta := range bulk.Meta {
+		for i := uint(0); i < 16; i++ {
+			if meta.PrimaryBit&(1<<i) != 0 {
+				expected += 4096 + 2048*3
+			}
+		}
+		expected += 256
+	}
+	if len(data) != expected {
+		t.Fatalf("Expected %d bytes of chunk data, got %d", expected, len(data))
+	}
+}
+
+func TestIllegalMoves(t *testing.T) {
+	c, err := Dial(serverAddress, "cheater", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	<-c.Spawned()
+	x, y, z := c.Position()
+	moves := []struct {
+		dx, dy, dz float64
+		violation  player.Violation
+	}{
+		{100, 0, 0, player.ViolationSpeed},
+		{0, -1.5, 0, player.ViolationCollision},
+		{0, 5, 0, player.ViolationFlying},
+		{math.NaN(), 0, 0, player.ViolationInvalid},
+	}
+	for _, move := range moves {
+		c.Move(x+move.dx, y+move.dy, z+move.dz)
+		select {
+		case v := <-violations:
+			if v != move.violation {
+				t.Fatalf("Expected a %s violation, got %s", move.violation, v)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Move %+v wasn't rejected", move)
+		}
+		waitFor(t, c, "move back", func() bool {
+			cx, cy, cz := c.Position()
+			return cx == x && cy == y && cz == z
+		})
+	}
+	//Normal movement is still allowed
+	c.Move(x+0.5, y, z)
+	time.Sleep(500 * time.Millisecond)
+	select {
+	case v := <-violations:
+		t.Fatalf("Walking rejected as %s", v)
+	default:
+	}
+}
+
+func TestEntityChangesChunk(t *testing.T) {
+	var lock sync.Mutex
+	visible := map[int32]bool{}
+	watcher, err := Dial(serverAddress, "spectator", func(c *Client, packet protocol.Packet) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch packet := packet.(type) {
+		case protocol.SpawnPlayer:
+			visible[int32(packet.EntityID)] = true
+		case protocol.EntityDestroy:
+			for _, id := range packet.EntityIDs {
+				visible[id] = false
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+	<-watcher.Spawned()
+
+	c, err := Dial(serverAddress, "runner", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	<-c.Spawned()
+	isVisible := func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return visible[c.EntityID()]
+	}
+	waitFor(t, watcher, "runner to spawn", isVisible)
+	//Beyond the watcher's view distance
+	x, y, z := c.Position()
+	c.Move(x+16*12, y, z)
+	waitFor(t, watcher, "runner to despawn", func() bool { return !isVisible() })
+	c.Move(x+8, y, z)
+	waitFor(t, watcher, "runner to come back", isVisible)
+}
+
+func TestTrackingRange(t *testing.T) {
+	var lock sync.Mutex
+	visible := map[string]map[int32]bool{}
+	track := func(c *Client, packet protocol.Packet) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch packet := packet.(type) {
+		case protocol.SpawnPlayer:
+			visible[c.Username][int32(packet.EntityID)] = true
+		case protocol.EntityDestroy:
+			for _, id := range packet.EntityIDs {
+				visible[c.Username][id] = false
+			}
+		}
+	}
+	var viewers []*Client
+	for _, name := range []string{"farsighted", "nearsighted"} {
+		visible[name] = map[int32]bool{}
+		c, err := Dial(serverAddress, name, track)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		<-c.Spawned()
+		viewers = append(viewers, c)
+	}
+	far, near := viewers[0], viewers[1]
+	near.QueuePacket(protocol.ClientSettings{Locale: "en_GB", ViewDistance: 4})
+	waitFor(t, near, "chunks to unload", func() bool { return near.ChunkCount() == 9*9 })
+
+	c, err := Dial(serverAddress, "sprinter", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	<-c.Spawned()
+	sees := func(viewer *Client) func() bool {
+		return func() bool {
+			lock.Lock()
+			defer lock.Unlock()
+			return visible[viewer.Username][c.EntityID()]
+		}
+	}
+	waitFor(t, far, "sprinter to spawn", sees(far))
+	waitFor(t, near, "sprinter to spawn", sees(near))
+	//Outside of the near viewer's view distance but inside the far one's
+	x, y, z := c.Position()
+	c.Move(x+16*6, y, z)
+	waitFor(t, near, "sprinter to despawn", func() bool { return !sees(near)() })
+	if !sees(far)() {
+		t.Fatal("Sprinter despawned for a viewer still in range")
+	}
+}
+
+func TestEntityMovesAccumulate(t *testing.T) {
+	var lock sync.Mutex
+	var id int32 = -1
+	var pos [3]int32
+	watcher, err := Dial(serverAddress, "surveyor", func(c *Client, packet protocol.Packet) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch packet := packet.(type) {
+		case protocol.SpawnPlayer:
+			if int32(packet.EntityID) == id {
+				pos = [3]int32{packet.X, packet.Y, packet.Z}
+			}
+		case protocol.EntityTeleport:
+			if packet.EntityID == id {
+				pos = [3]int32{packet.X, packet.Y, packet.Z}
+			}
+		case protocol.EntityMove:
+			if packet.EntityID == id {
+				pos[0] += int32(packet.DX)
+				pos[1] += int32(packet.DY)
+				pos[2] += int32(packet.DZ)
+			}
+		case protocol.EntityLookMove:
+			if packet.EntityID == id {
+				pos[0] += int32(packet.DX)
+				pos[1] += int32(packet.DY)
+				pos[2] += int32(packet.DZ)
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+	<-watcher.Spawned()
+
+	c, err := Dial(serverAddress, "drifter", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	<-c.Spawned()
+	lock.Lock()
+	id = c.EntityID()
+	lock.Unlock()
+	at := func(x, y, z float64) func() bool {
+		return func() bool {
+			lock.Lock()
+			defer lock.Unlock()
+			return pos == [3]int32{int32(math.Floor(x * 32)), int32(math.Floor(y * 32)), int32(math.Floor(z * 32))}
+		}
+	}
+	x, y, z := c.Position()
+	//Small moves that don't land on whole fixed point steps
+	for i := 0; i < 20; i++ {
+		x -= 0.37
+		z += 0.13
+		c.Move(x, y, z)
+		time.Sleep(60 * time.Millisecond)
+	}
+	waitFor(t, watcher, "small moves", at(x, y, z))
+	//Too far for a relative move
+	x -= 6
+	c.Move(x, y, z)
+	waitFor(t, watcher, "large move", at(x, y, z))
+}
+
+func TestSneakingShown(t *testing.T) {
+	var lock sync.Mutex
+	var id int32 = -1
+	var flags int8
+	watcher, err := Dial(serverAddress, "lookout", func(c *Client, packet protocol.Packet) {
+		lock.Lock()
+		defer lock.Unlock()
+		if packet, ok := packet.(protocol.EntityMetadata); ok && packet.EntityID == id {
+			if f, ok := packet.Metadata[0].(int8); ok {
+				flags = f
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+	<-watcher.Spawned()
+
+	c, err := Dial(serverAddress, "sneaker", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	<-c.Spawned()
+	lock.Lock()
+	id = c.EntityID()
+	lock.Unlock()
+	flag := func(want int8) func() bool {
+		return func() bool {
+			lock.Lock()
+			defer lock.Unlock()
+			return flags == want
+		}
+	}
+	c.QueuePacket(protocol.EntityAction{EntityID: c.EntityID(), ActionID: 1})
+	waitFor(t, watcher, "sneaking", flag(0x02))
+	c.QueuePacket(protocol.EntityAction{EntityID: c.EntityID(), ActionID: 4})
+	waitFor(t, watcher, "sprinting", flag(0x0A))
+	c.QueuePacket(protocol.EntityAction{EntityID: c.EntityID(), ActionID: 2})
+	waitFor(t, watcher, "standing", flag(0x08))
+}
+
+func TestKnockbackShown(t *testing.T) {
+	var lock sync.Mutex
+	var id int32 = -1
+	seen, knocked := false, false
+	attacker, err := Dial(serverAddress, "attacker", func(c *Client, packet protocol.Packet) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch packet := packet.(type) {
+		case protocol.SpawnPlayer:
+			seen = seen || int32(packet.EntityID) == id
+		case protocol.EntityVelocity:
+			knocked = knocked || packet.EntityID == id
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer attacker.Close()
+	<-attacker.Spawned()
+
+	victim, err := Dial(serverAddress, "victim", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer victim.Close()
+	<-victim.Spawned()
+	lock.Lock()
+	id = victim.EntityID()
+	lock.Unlock()
+	waitFor(t, attacker, "victim to spawn", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return seen
+	})
+	attacker.QueuePacket(protocol.UseEntity{Target: id, Mouse: 1})
+	waitFor(t, attacker, "victim's knockback", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return knocked
+	})
+}
+
+func TestEntitiesSpawned(t *testing.T) {
+	var lock sync.Mutex
+	var item, mob int32 = -1, -1
+	var stack protocol.Slot
+	var itemY int32
+	spotted := false
+	c, err := Dial(serverAddress, "spawner", func(c *Client, packet protocol.Packet) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch packet := packet.(type) {
+		case protocol.SpawnPlayer:
+			if packet.PlayerName == "spotter" {
+				spotted = true
+			}
+		case protocol.SpawnObject:
+			if packet.Type == 2 {
+				item = int32(packet.EntityID)
+				itemY = packet.Y
+			}
+		case protocol.SpawnMob:
+			if packet.Type == 54 {
+				mob = int32(packet.EntityID)
+			}
+		case protocol.EntityMetadata:
+			if s, ok := packet.Metadata[10].(protocol.Slot); ok && packet.EntityID == item {
+				stack = s
+			}
+		case protocol.EntityTeleport:
+			if packet.EntityID == item {
+				itemY = packet.Y
+			}
+		case protocol.EntityMove:
+			if packet.EntityID == item {
+				itemY += int32(packet.DY)
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	<-c.Spawned()
+	//Seeing another player means the world is tracking entities for
+	//the client so the item won't be spawned before it can be seen
+	spotter, err := Dial(serverAddress, "spotter", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer spotter.Close()
+	waitFor(t, c, "spotter", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return spotted
+	})
+	_, y, _ := c.Position()
+	c.Chat("item")
+	waitFor(t, c, "item to spawn", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return item != -1
+	})
+	waitFor(t, c, "item stack", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return stack.ID == 1 && stack.Count == 3
+	})
+	waitFor(t, c, "item to land", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return itemY == int32(math.Floor(y*32))
+	})
+	c.Chat("zombie")
+	waitFor(t, c, "zombie", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return mob != -1
+	})
+}
+
+func TestEntitiesSaved(t *testing.T) {
+	var lock sync.Mutex
+	found := false
+	c, err := Dial(serverAddress, "hoarder", func(c *Client, packet protocol.Packet) {
+		if packet, ok := packet.(protocol.EntityMetadata); ok {
+			if s, ok := packet.Metadata[10].(protocol.Slot); ok && s.ID == 4 {
+				lock.Lock()
+				found = true
+				lock.Unlock()
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	<-c.Spawned()
+	c.Chat("stash")
+	//Give the item's chunk time to unload
+	time.Sleep(time.Second)
+	_, y, _ := c.Position()
+	c.Move(40*16+8, y, 8)
+	waitFor(t, c, "stashed item", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return found
+	})
+}
@@ -0,0 +1,364 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/NetherrackDev/netherrack/client"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+//A path returns the offset from the bot's center at time t (in seconds)
+//when walking at speed blocks per a second
+type path func(t, speed, radius float64) (dx, dz float64)
+
+var paths = map[string]func(r *rand.Rand) path{
+	"still": func(*rand.Rand) path {
+		return func(t, speed, radius float64) (float64, float64) { return 0, 0 }
+	},
+	"line": func(*rand.Rand) path {
+		return func(t, speed, radius float64) (float64, float64) {
+			//Walks back and forth between -radius and radius
+			d := math.Mod(t*speed, radius*4)
+			if d > radius*2 {
+				d = radius*4 - d
+			}
+			return d - radius, 0
+		}
+	},
+	"circle": func(*rand.Rand) path {
+		return func(t, speed, radius float64) (float64, float64) {
+			a := t * speed / radius
+			return math.Cos(a) * radius, math.Sin(a) * radius
+		}
+	},
+	"square": func(*rand.Rand) path {
+		return func(t, speed, radius float64) (float64, float64) {
+			side := radius * 2
+			d := math.Mod(t*speed, side*4)
+			switch {
+			case d < side:
+				return d - radius, -radius
+			case d < side*2:
+				return radius, d - side - radius
+			case d < side*3:
+				return radius - (d - side*2), radius
+			}
+			return -radius, radius - (d - side*3)
+		}
+	},
+	"random": func(r *rand.Rand) path {
+		var x, z, lastT float64
+		heading := r.Float64() * math.Pi * 2
+		return func(t, speed, radius float64) (float64, float64) {
+			dt := t - lastT
+			lastT = t
+			if r.Intn(20) == 0 {
+				heading += (r.Float64() - 0.5) * math.Pi
+			}
+			x += math.Cos(heading) * speed * dt
+			z += math.Sin(heading) * speed * dt
+			if x*x+z*z > radius*radius {
+				//Turn back towards the center
+				heading = math.Atan2(-z, -x)
+			}
+			return x, z
+		}
+	},
+}
+
+type bot struct {
+	id    int
+	opts  *options
+	stats *results
+	rand  *rand.Rand
+
+	centerX, centerZ float64
+
+	joined       time.Time
+	joinComplete bool
+
+	chunkLock sync.Mutex
+	pending   map[[2]int]time.Time
+
+	chatLock sync.Mutex
+	chatSeq  int
+	chats    map[string]time.Time
+
+	lastKeepAlive time.Time
+
+	//When the window clicks following keep alive replies were sent by
+	//action number
+	keepAliveLock sync.Mutex
+	keepAliveSeq  int16
+	keepAlives    map[int16]time.Time
+
+	//The client is read by the status loop whilst the bot connects
+	clientLock sync.Mutex
+	client     *client.Client
+}
+
+func newBot(id int, opts *options, stats *results) *bot {
+	r := rand.New(rand.NewSource(int64(id)))
+	return &bot{
+		id:      id,
+		opts:    opts,
+		stats:   stats,
+		rand:    r,
+		centerX: (r.Float64() - 0.5) * opts.spread,
+		centerZ: (r.Float64() - 0.5) * opts.spread,
+		pending: map[[2]int]time.Time{},
+		chats:   map[string]time.Time{},
+
+		keepAlives: map[int16]time.Time{},
+	}
+}
+
+func (b *bot) name() string {
+	return fmt.Sprintf("bot%d", b.id)
+}
+
+//Connects the bot and runs it until stop is closed or the
+//connection fails
+func (b *bot) run(stop <-chan struct{}) error {
+	b.joined = time.Now()
+	c, err := client.Dial(b.opts.addr, b.name(), b.handle)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	b.clientLock.Lock()
+	b.client = c
+	b.clientLock.Unlock()
+
+	select {
+	case <-c.Spawned():
+	case <-c.ClosedChannel:
+		return c.Err()
+	case <-stop:
+		return nil
+	}
+	x, y, z := c.Position()
+	cx, cz := chunkPos(x, z)
+
+	walk := paths[b.opts.path](b.rand)
+	//The bot walks to the start of its path instead of jumping there
+	//so the server doesn't reject the move. The path starts once it
+	//has arrived.
+	dx, dz := walk(0, b.opts.speed, b.opts.radius)
+	startX, startZ := b.centerX+dx, b.centerZ+dz
+	var start time.Time
+
+	tick := time.NewTicker(time.Second / 20)
+	defer tick.Stop()
+	var chat, build <-chan time.Time
+	if b.opts.chat > 0 {
+		t := time.NewTicker(b.opts.chat)
+		defer t.Stop()
+		chat = t.C
+	}
+	if b.opts.build > 0 {
+		t := time.NewTicker(b.opts.build)
+		defer t.Stop()
+		build = t.C
+	}
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-c.ClosedChannel:
+			return c.Err()
+		case now := <-tick.C:
+			lx, lz := x, z
+			if start.IsZero() {
+				x, z = stepTowards(x, z, startX, startZ, b.opts.speed/20)
+				if x == startX && z == startZ {
+					start = now
+				}
+			} else {
+				dx, dz := walk(now.Sub(start).Seconds(), b.opts.speed, b.opts.radius)
+				x, z = b.centerX+dx, b.centerZ+dz
+			}
+			c.Move(x, y, z)
+			if x != lx || z != lz {
+				c.Look(float32(math.Atan2(z-lz, x-lx)*180/math.Pi), 0)
+			}
+			ncx, ncz := chunkPos(x, z)
+			if ncx != cx || ncz != cz {
+				b.movedChunk(c, ncx, ncz)
+				cx, cz = ncx, ncz
+			}
+		case <-chat:
+			b.chatLock.Lock()
+			b.chatSeq++
+			token := fmt.Sprintf("lt:%d:%d", b.id, b.chatSeq)
+			b.chats[token] = time.Now()
+			b.chatLock.Unlock()
+			c.Chat(token)
+		case <-build:
+			bx, bz := int(math.Floor(x)), int(math.Floor(z))
+			c.PlaceBlock(bx, groundLevel, bz, 1, protocol.Slot{ID: 1, Count: 1})
+			c.DigBlock(bx, groundLevel+1, bz, 1)
+		}
+	}
+}
+
+//Records the chunks that should be sent now the bot has entered a new chunk
+func (b *bot) movedChunk(c *client.Client, cx, cz int) {
+	now := time.Now()
+	view := b.opts.view
+	b.chunkLock.Lock()
+	defer b.chunkLock.Unlock()
+	for x := cx - view; x <= cx+view; x++ {
+		for z := cz - view; z <= cz+view; z++ {
+			if !c.Chunk(x, z) {
+				if _, ok := b.pending[[2]int{x, z}]; !ok {
+					b.pending[[2]int{x, z}] = now
+				}
+			}
+		}
+	}
+}
+
+//Times the keep alive reply the client has just queued. The server
+//doesn't answer keep alives so a click outside of the inventory, which
+//changes nothing, is sent straight after it. The server handles the
+//player's packets in order so the click's confirmation arrives once the
+//reply has been round to the server and back.
+func (b *bot) timeKeepAlive(c *client.Client) {
+	b.keepAliveLock.Lock()
+	b.keepAliveSeq++
+	action := b.keepAliveSeq
+	b.keepAlives[action] = time.Now()
+	b.keepAliveLock.Unlock()
+	c.QueuePacket(protocol.WindowClick{
+		WindowID:     0,
+		Slot:         -999,
+		ActionNumber: action,
+		Item:         protocol.Slot{ID: -1},
+	})
+}
+
+//Called on the client's read goroutine for every packet
+func (b *bot) handle(c *client.Client, packet protocol.Packet) {
+	switch packet := packet.(type) {
+	case protocol.KeepAlive:
+		//The server sends keep alives every 15 seconds so any extra
+		//time is how far behind the player's loop is running
+		now := time.Now()
+		if !b.lastKeepAlive.IsZero() {
+			lag := now.Sub(b.lastKeepAlive) - keepAlivePeriod
+			if lag < 0 {
+				lag = 0
+			}
+			b.stats.keepAliveLate.Add(lag)
+		}
+		b.lastKeepAlive = now
+		b.timeKeepAlive(c)
+	case protocol.WindowTransactionConfirm:
+		if packet.WindowID != 0 {
+			return
+		}
+		b.keepAliveLock.Lock()
+		sent, ok := b.keepAlives[packet.ActionNumber]
+		delete(b.keepAlives, packet.ActionNumber)
+		b.keepAliveLock.Unlock()
+		if !ok {
+			return
+		}
+		b.stats.keepAliveRTT.Add(time.Since(sent))
+		if !packet.Accepted {
+			//The server waits for rejected clicks to be acknowledged
+			c.QueuePacket(protocol.ClientWindowTransactionConfirm{
+				WindowID:     0,
+				ActionNumber: packet.ActionNumber,
+			})
+		}
+	case protocol.ChunkData:
+		if packet.GroundUp && packet.PrimaryBitMap == 0 {
+			return
+		}
+		b.chunkArrived(c, int(packet.X), int(packet.Z))
+	case protocol.MapChunkBulk:
+		for _, meta := range packet.Meta {
+			b.chunkArrived(c, int(meta.X), int(meta.Z))
+		}
+	case protocol.ServerMessage:
+		if !strings.Contains(packet.Message, fmt.Sprintf("lt:%d:", b.id)) {
+			return
+		}
+		b.chatLock.Lock()
+		defer b.chatLock.Unlock()
+		for token, sent := range b.chats {
+			if strings.Contains(packet.Message, token+"\"") || strings.HasSuffix(packet.Message, token) {
+				b.stats.chat.Add(time.Since(sent))
+				delete(b.chats, token)
+				return
+			}
+		}
+	}
+}
+
+func (b *bot) chunkArrived(c *client.Client, x, z int) {
+	now := time.Now()
+	if !b.joinComplete {
+		b.stats.chunkJoin.Add(now.Sub(b.joined))
+		size := b.opts.view*2 + 1
+		if c.ChunkCount() >= size*size {
+			b.joinComplete = true
+			b.stats.joinComplete.Add(now.Sub(b.joined))
+		}
+		return
+	}
+	b.chunkLock.Lock()
+	defer b.chunkLock.Unlock()
+	if sent, ok := b.pending[[2]int{x, z}]; ok {
+		b.stats.chunkMove.Add(now.Sub(sent))
+		delete(b.pending, [2]int{x, z})
+	}
+}
+
+//Returns the position after moving up to step blocks from x, z
+//towards tx, tz
+func stepTowards(x, z, tx, tz, step float64) (float64, float64) {
+	dx, dz := tx-x, tz-z
+	d := math.Hypot(dx, dz)
+	if d <= step {
+		return tx, tz
+	}
+	return x + dx/d*step, z + dz/d*step
+}
+
+//Returns the number of packets the bot has read and written
+func (b *bot) packetCount() (read, written uint64) {
+	b.clientLock.Lock()
+	c := b.client
+	b.clientLock.Unlock()
+	if c == nil {
+		return 0, 0
+	}
+	return c.PacketCount()
+}
+
+func chunkPos(x, z float64) (int, int) {
+	return int(math.Floor(x)) >> 4, int(math.Floor(z)) >> 4
+}
@@ -0,0 +1,295 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+/*
+	Loadtest connects a swarm of headless bots to a server and reports
+	how well it copes. Unless -addr is given a local server with a
+	superflat world is started in a temporary directory so the test can
+	run entirely on localhost (e.g. in CI).
+
+	Each bot walks a path around its own center, optionally chats and
+	places/breaks blocks. Once the duration is over percentiles are
+	printed for
+	    keepalive-late how late keep alives arrive compared to the server's 15 second period
+	    keepalive-rtt  the round trip of each bot's keep alive reply
+	    chat           the round trip time of a chat message being broadcast back
+	    chunk-join     the time from logging in to each initial chunk arriving
+	    join-full      the time from logging in to the full view being loaded
+	    chunk-move     the time from entering a chunk to the newly visible chunks arriving
+	along with the packet throughput of all the bots.
+
+	The process exits with a non-zero status if any bot failed to connect
+	or was disconnected before the end of the run.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/NetherrackDev/netherrack"
+	"github.com/NetherrackDev/netherrack/entity/player"
+	"github.com/NetherrackDev/netherrack/message"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"github.com/NetherrackDev/netherrack/world"
+	"github.com/NetherrackDev/netherrack/world/flat"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	keepAlivePeriod = 15 * time.Second
+	//The top layer of the local server's superflat world
+	groundLevel = 3
+)
+
+type options struct {
+	addr     string
+	bots     int
+	duration time.Duration
+	ramp     time.Duration
+	path     string
+	radius   float64
+	speed    float64
+	spread   float64
+	chat     time.Duration
+	build    time.Duration
+	view     int
+}
+
+type results struct {
+	keepAliveLate latencyStats
+	keepAliveRTT  latencyStats
+	chat          latencyStats
+	chunkJoin     latencyStats
+	joinComplete  latencyStats
+	chunkMove     latencyStats
+}
+
+func main() {
+	opts := &options{}
+	flag.StringVar(&opts.addr, "addr", "", "address of the server to test, a local server is started if empty")
+	flag.IntVar(&opts.bots, "bots", 10, "number of bots to connect")
+	flag.DurationVar(&opts.duration, "duration", time.Minute, "how long to run the bots for once connected")
+	flag.DurationVar(&opts.ramp, "ramp", 50*time.Millisecond, "delay between connecting each bot")
+	flag.StringVar(&opts.path, "path", "circle", "path the bots walk: "+pathNames())
+	flag.Float64Var(&opts.radius, "radius", 24, "size of the path in blocks")
+	flag.Float64Var(&opts.speed, "speed", 4.3, "walking speed in blocks per a second")
+	flag.Float64Var(&opts.spread, "spread", 64, "size of the area the bots' paths are centered in")
+	flag.DurationVar(&opts.chat, "chat", 5*time.Second, "interval between chat messages, 0 disables")
+	flag.DurationVar(&opts.build, "build", 2*time.Second, "interval between placing and breaking blocks, 0 disables")
+	flag.IntVar(&opts.view, "view", 10, "the server's view distance in chunks")
+	flag.Parse()
+
+	if _, ok := paths[opts.path]; !ok {
+		log.Fatalf("Unknown path %q, expected one of %s", opts.path, pathNames())
+	}
+	if opts.radius <= 0 {
+		opts.radius = 1
+	}
+
+	var local *localServer
+	if opts.addr == "" {
+		dir, err := ioutil.TempDir("", "netherrack-loadtest")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		local = startLocalServer(dir)
+		opts.addr = local.addr
+		log.Printf("Started local server on %s", opts.addr)
+	}
+
+	stats := &results{
+		keepAliveLate: latencyStats{Name: "keepalive-late"},
+		keepAliveRTT:  latencyStats{Name: "keepalive-rtt"},
+		chat:          latencyStats{Name: "chat"},
+		chunkJoin:     latencyStats{Name: "chunk-join"},
+		joinComplete:  latencyStats{Name: "join-full"},
+		chunkMove:     latencyStats{Name: "chunk-move"},
+	}
+
+	stop := make(chan struct{})
+	var wait sync.WaitGroup
+	var failed, connected int32
+	bots := make([]*bot, opts.bots)
+	errors := map[string]int{}
+	var errorLock sync.Mutex
+
+	log.Printf("Connecting %d bots to %s", opts.bots, opts.addr)
+	for i := range bots {
+		b := newBot(i, opts, stats)
+		bots[i] = b
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			atomic.AddInt32(&connected, 1)
+			defer atomic.AddInt32(&connected, -1)
+			if err := b.run(stop); err != nil {
+				atomic.AddInt32(&failed, 1)
+				errorLock.Lock()
+				errors[err.Error()]++
+				errorLock.Unlock()
+			}
+		}()
+		time.Sleep(opts.ramp)
+	}
+
+	start := time.Now()
+	end := time.After(opts.duration)
+	status := time.NewTicker(10 * time.Second)
+	var lastRead, lastWritten uint64
+	lastStatus := start
+run:
+	for {
+		select {
+		case <-end:
+			break run
+		case now := <-status.C:
+			read, written := packetCount(bots)
+			secs := now.Sub(lastStatus).Seconds()
+			log.Printf("%d bots running, %d failed, %.0f packets/s in, %.0f packets/s out",
+				atomic.LoadInt32(&connected), atomic.LoadInt32(&failed),
+				float64(read-lastRead)/secs, float64(written-lastWritten)/secs)
+			lastRead, lastWritten, lastStatus = read, written, now
+		}
+	}
+	status.Stop()
+	read, written := packetCount(bots)
+	elapsed := time.Since(start)
+	close(stop)
+	wait.Wait()
+
+	fmt.Println()
+	reportHeader(os.Stdout)
+	stats.keepAliveLate.Report(os.Stdout)
+	stats.keepAliveRTT.Report(os.Stdout)
+	stats.chat.Report(os.Stdout)
+	stats.chunkJoin.Report(os.Stdout)
+	stats.joinComplete.Report(os.Stdout)
+	stats.chunkMove.Report(os.Stdout)
+	fmt.Println()
+	fmt.Printf("packets in:  %d (%.0f/s)\n", read, float64(read)/elapsed.Seconds())
+	fmt.Printf("packets out: %d (%.0f/s)\n", written, float64(written)/elapsed.Seconds())
+	fmt.Printf("bots failed: %d/%d\n", failed, opts.bots)
+	for err, count := range errors {
+		fmt.Printf("    %4d %s\n", count, err)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func packetCount(bots []*bot) (read, written uint64) {
+	for _, b := range bots {
+		r, w := b.packetCount()
+		read += r
+		written += w
+	}
+	return
+}
+
+func pathNames() string {
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+//Starts a server in dir listening on a random localhost port
+func startLocalServer(dir string) *localServer {
+	//Worlds are saved relative to the working directory
+	if err := os.Chdir(dir); err != nil {
+		log.Fatal(err)
+	}
+	server := netherrack.NewServer()
+	ls := &localServer{server: server}
+	server.Handler = ls
+	server.SetAuthenticator(nil)
+	server.LoadWorld("loadtest", &world.MsgpackSystem{}, flat.ClassicFlat, world.Overworld)
+	server.SetDefaultWorld("loadtest")
+
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatal(err)
+	}
+	go server.Serve(listen)
+	ls.addr = listen.Addr().String()
+	return ls
+}
+
+type localServer struct {
+	server *netherrack.Server
+	addr   string
+}
+
+func (ls *localServer) PlayerJoin(p *player.Player) (bool, string) {
+	p.Handler = localPlayer{p, ls}
+	return false, ""
+}
+
+//A basic player handler that allows building and chatting
+type localPlayer struct {
+	p      *player.Player
+	server *localServer
+}
+
+func (localPlayer) EnterWorld(*protocol.JoinGame) {}
+
+func (lp localPlayer) BlockPlacement(packet protocol.PlayerBlockPlacement) {
+	if packet.HeldItem.ID <= 0 || packet.HeldItem.ID > 255 {
+		return
+	}
+	x, y, z := int(packet.X), int(packet.Y), int(packet.Z)
+	switch packet.Direction {
+	case 0:
+		y--
+	case 1:
+		y++
+	case 2:
+		z--
+	case 3:
+		z++
+	case 4:
+		x--
+	case 5:
+		x++
+	default:
+		return
+	}
+	lp.p.World.SetBlock(x, y, z, byte(packet.HeldItem.ID), 0)
+}
+
+func (lp localPlayer) BlockDig(packet protocol.PlayerDigging) {
+	if packet.Status != 2 {
+		return
+	}
+	lp.p.World.SetBlock(int(packet.X), int(packet.Y), int(packet.Z), 0, 0)
+}
+
+func (lp localPlayer) Chat(msg string) {
+	lp.server.server.SendMessage(&message.Message{Text: "<" + lp.p.Username + "> " + msg})
+}
+
+func (lp localPlayer) Leave() {}
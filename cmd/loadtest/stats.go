@@ -0,0 +1,84 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+//Collects duration samples and reports percentiles
+type latencyStats struct {
+	sync.Mutex
+	Name    string
+	samples []time.Duration
+}
+
+func (ls *latencyStats) Add(d time.Duration) {
+	ls.Lock()
+	ls.samples = append(ls.samples, d)
+	ls.Unlock()
+}
+
+//Returns the duration at the percentile (0-100). Must be called with
+//the samples sorted.
+func (ls *latencyStats) percentile(p float64) time.Duration {
+	if len(ls.samples) == 0 {
+		return 0
+	}
+	idx := int(float64(len(ls.samples)-1) * p / 100)
+	return ls.samples[idx]
+}
+
+func (ls *latencyStats) Report(w io.Writer) {
+	ls.Lock()
+	defer ls.Unlock()
+	sort.Sort(durationSorter(ls.samples))
+	if len(ls.samples) == 0 {
+		fmt.Fprintf(w, "%-16s %8s\n", ls.Name, "no samples")
+		return
+	}
+	fmt.Fprintf(w, "%-16s %8d %10s %10s %10s %10s %10s\n",
+		ls.Name,
+		len(ls.samples),
+		round(ls.percentile(50)),
+		round(ls.percentile(90)),
+		round(ls.percentile(95)),
+		round(ls.percentile(99)),
+		round(ls.samples[len(ls.samples)-1]),
+	)
+}
+
+func reportHeader(w io.Writer) {
+	fmt.Fprintf(w, "%-16s %8s %10s %10s %10s %10s %10s\n",
+		"metric", "samples", "p50", "p90", "p95", "p99", "max")
+}
+
+func round(d time.Duration) time.Duration {
+	return d - d%(10*time.Microsecond)
+}
+
+type durationSorter []time.Duration
+
+func (ds durationSorter) Len() int { return len(ds) }
+
+func (ds durationSorter) Less(i, j int) bool { return ds[i] < ds[j] }
+
+func (ds durationSorter) Swap(i, j int) { ds[i], ds[j] = ds[j], ds[i] }
@@ -0,0 +1,97 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+/*
+	Replay reads packet captures recorded by a server with
+	SetCaptureDirectory.
+
+	By default the capture is dumped as json, one packet per a line
+	    replay player-1389323.nrcap
+	With -addr the player's packets are sent to a server instead, keeping
+	the original timing
+	    replay -addr localhost:25565 -username tester player-1389323.nrcap
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"github.com/NetherrackDev/netherrack/protocol/capture"
+	"log"
+	"net"
+	"os"
+	"strconv"
+)
+
+func main() {
+	addr := flag.String("addr", "", "address of a server to replay the capture against")
+	username := flag.String("username", "replay", "username to log in with when replaying")
+	speed := flag.Float64("speed", 1, "playback speed when replaying")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] capture\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	r, err := capture.NewReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *addr == "" {
+		if err := capture.DumpJSON(os.Stdout, r); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	host, portStr, err := net.SplitHostPort(*addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	mcConn := &protocol.Conn{
+		Out:            conn,
+		In:             conn,
+		Deadliner:      conn,
+		ReadDirection:  protocol.Clientbound,
+		WriteDirection: protocol.Serverbound,
+	}
+	if _, err := mcConn.ClientLogin(host, uint16(port), *username); err != nil {
+		log.Fatal(err)
+	}
+	if err := capture.Replay(mcConn, r, *speed); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -17,6 +17,8 @@
 package entity
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"github.com/NetherrackDev/netherrack/world"
 	"sync"
 )
@@ -24,12 +26,12 @@ import (
 type EntityComponent struct {
 	ID     int32
 	Uuid   string
-	Server Server
-	World  *world.World
+	Server Server       `msgpack:"ignore"`
+	World  *world.World `msgpack:"ignore"`
 
 	CurrentTick uint64
 
-	Systems []System
+	Systems []System `msgpack:"ignore"`
 }
 
 func (e *EntityComponent) Init(root interface{}) {
@@ -103,3 +105,39 @@ func FreeID(id int32) {
 	defer ueiLock.Unlock()
 	delete(usedEntityIDs, id)
 }
+
+//Returns a random (version 4) uuid for entities that aren't players
+func NewUUID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(err)
+	}
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return hex.EncodeToString(id[:])
+}
+
+var entities = struct {
+	sync.RWMutex
+	m map[int32]interface{}
+}{m: map[int32]interface{}{}}
+
+//Makes the entity available through ByID until it is unregistered
+func Register(id int32, e interface{}) {
+	entities.Lock()
+	defer entities.Unlock()
+	entities.m[id] = e
+}
+
+func Unregister(id int32) {
+	entities.Lock()
+	defer entities.Unlock()
+	delete(entities.m, id)
+}
+
+//Returns the registered entity with the id or nil if there isn't one
+func ByID(id int32) interface{} {
+	entities.RLock()
+	defer entities.RUnlock()
+	return entities.m[id]
+}
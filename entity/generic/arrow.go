@@ -0,0 +1,90 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package generic
+
+import (
+	"github.com/NetherrackDev/netherrack/entity"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"github.com/NetherrackDev/netherrack/world"
+)
+
+const (
+	objectArrow = 60
+	//Ticks arrows stay once they have landed
+	arrowLifetime = ticksPerSecond * 60
+)
+
+//A fired arrow. Arrows disappear a while after landing.
+type Arrow struct {
+	Base
+
+	//Ticks since the arrow landed
+	Landed int32
+}
+
+//Returns an arrow ready to be spawned, its velocity should be set
+//before spawning it
+func NewArrow() *Arrow {
+	a := &Arrow{}
+	a.Gravity, a.Drag = 20, 0.2
+	return a
+}
+
+func (a *Arrow) prepare() {}
+
+func (a *Arrow) tick() {
+	if !a.OnGround {
+		return
+	}
+	a.Landed++
+	if a.Landed >= arrowLifetime {
+		a.Remove()
+	}
+}
+
+func (a *Arrow) SpawnPackets() []protocol.Packet {
+	x, y, z := a.fixedPosition()
+	vx, vy, vz := a.packedVelocity()
+	return []protocol.Packet{
+		protocol.SpawnObject{
+			EntityID: protocol.VarInt(a.ID),
+			Type:     objectArrow,
+			X:        x,
+			Y:        y,
+			Z:        z,
+			Pitch:    entity.PackAngle(a.Pitch),
+			Yaw:      entity.PackAngle(a.Yaw),
+			//Non zero so the speed is sent
+			ExtraData: 1,
+			SpeedX:    vx,
+			SpeedY:    vy,
+			SpeedZ:    vz,
+		},
+	}
+}
+
+func (a *Arrow) TrackingKind() world.TrackingKind {
+	return world.TrackProjectile
+}
+
+func (a *Arrow) Snapshot() world.Entity {
+	return saved{a.Uuid, *a}
+}
+
+func (a Arrow) Load(w *world.World) {
+	go Spawn(&a, w, a.X, a.Y, a.Z)
+}
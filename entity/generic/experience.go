@@ -0,0 +1,75 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package generic
+
+import (
+	"github.com/NetherrackDev/netherrack/protocol"
+	"github.com/NetherrackDev/netherrack/world"
+)
+
+//Ticks before experience orbs disappear
+const experienceLifetime = ticksPerSecond * 60 * 5
+
+//An orb worth an amount of experience
+type ExperienceOrb struct {
+	Base
+
+	Count int16
+	//Ticks since the orb was spawned
+	Age int32
+}
+
+//Returns an experience orb worth count experience ready to be spawned
+func NewExperienceOrb(count int16) *ExperienceOrb {
+	o := &ExperienceOrb{Count: count}
+	o.Gravity, o.Drag = 12, 0.4
+	return o
+}
+
+func (o *ExperienceOrb) prepare() {}
+
+func (o *ExperienceOrb) tick() {
+	o.Age++
+	if o.Age >= experienceLifetime {
+		o.Remove()
+	}
+}
+
+func (o *ExperienceOrb) SpawnPackets() []protocol.Packet {
+	x, y, z := o.fixedPosition()
+	return []protocol.Packet{
+		protocol.SpawnExperienceOrb{
+			EntityID: protocol.VarInt(o.ID),
+			X:        x,
+			Y:        y,
+			Z:        z,
+			Count:    o.Count,
+		},
+	}
+}
+
+func (o *ExperienceOrb) TrackingKind() world.TrackingKind {
+	return world.TrackExperience
+}
+
+func (o *ExperienceOrb) Snapshot() world.Entity {
+	return saved{o.Uuid, *o}
+}
+
+func (o ExperienceOrb) Load(w *world.World) {
+	go Spawn(&o, w, o.X, o.Y, o.Z)
+}
@@ -0,0 +1,82 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package generic
+
+import (
+	"github.com/NetherrackDev/netherrack/entity"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"github.com/NetherrackDev/netherrack/world"
+	"math"
+)
+
+const objectFallingBlock = 70
+
+//A block that falls until it lands, where it is placed again
+type FallingBlock struct {
+	Base
+
+	Block, Data byte
+}
+
+//Returns a falling block ready to be spawned
+func NewFallingBlock(block, data byte) *FallingBlock {
+	f := &FallingBlock{Block: block, Data: data}
+	f.Gravity, f.Drag = 16, 0.4
+	return f
+}
+
+func (f *FallingBlock) prepare() {}
+
+func (f *FallingBlock) tick() {
+	if !f.OnGround {
+		return
+	}
+	f.World.SetBlock(int(math.Floor(f.X)), int(math.Floor(f.Y)), int(math.Floor(f.Z)), f.Block, f.Data)
+	f.Remove()
+}
+
+func (f *FallingBlock) SpawnPackets() []protocol.Packet {
+	x, y, z := f.fixedPosition()
+	vx, vy, vz := f.packedVelocity()
+	return []protocol.Packet{
+		protocol.SpawnObject{
+			EntityID:  protocol.VarInt(f.ID),
+			Type:      objectFallingBlock,
+			X:         x,
+			Y:         y,
+			Z:         z,
+			Pitch:     entity.PackAngle(f.Pitch),
+			Yaw:       entity.PackAngle(f.Yaw),
+			ExtraData: int32(f.Block) | int32(f.Data)<<16,
+			SpeedX:    vx,
+			SpeedY:    vy,
+			SpeedZ:    vz,
+		},
+	}
+}
+
+func (f *FallingBlock) TrackingKind() world.TrackingKind {
+	return world.TrackOther
+}
+
+func (f *FallingBlock) Snapshot() world.Entity {
+	return saved{f.Uuid, *f}
+}
+
+func (f FallingBlock) Load(w *world.World) {
+	go Spawn(&f, w, f.X, f.Y, f.Z)
+}
@@ -0,0 +1,213 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+//Package generic contains the entities that aren't players: dropped items,
+//falling blocks, arrows, experience orbs and mobs. Each entity runs in
+//its own goroutine once spawned and is saved with the chunk it is in.
+//Entities in chunks that nobody is watching stop until the chunk is
+//loaded again.
+package generic
+
+import (
+	"github.com/NetherrackDev/netherrack/entity"
+	"github.com/NetherrackDev/netherrack/format/msgpack"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"github.com/NetherrackDev/netherrack/world"
+	"time"
+)
+
+const (
+	//Entities are updated 10 times a second
+	ticksPerSecond = 10
+	//Ticks between updating the copy of the entity saved with its chunk
+	saveInterval = ticksPerSecond * 10
+	//Entities that fall below this are removed
+	voidLevel = -64
+)
+
+func init() {
+	msgpack.Register(saved{})
+	msgpack.Register(Item{})
+	msgpack.Register(FallingBlock{})
+	msgpack.Register(Arrow{})
+	msgpack.Register(ExperienceOrb{})
+	msgpack.Register(Mob{})
+}
+
+//Implemented by every entity in this package
+type Entity interface {
+	world.Entity
+	world.Trackable
+	Entity() *entity.EntityComponent
+	Position() *entity.PositionComponent
+	Velocity() *entity.VelocityComponent
+	SpawnPackets() []protocol.Packet
+	DespawnPackets() []protocol.Packet
+	Remove()
+	base() *Base
+	//Sets up the parts of the entity that aren't saved
+	prepare()
+	//Runs the entity's own behaviour after its systems each tick
+	tick()
+}
+
+//The copy of an entity that is saved with its chunk. msgpack can only
+//store the entity as a value but the entity's methods need a pointer so
+//the copy is wrapped.
+type saved struct {
+	Uuid   string
+	Entity world.Loadable
+}
+
+func (s saved) UUID() string {
+	return s.Uuid
+}
+
+func (saved) Saveable() bool {
+	return true
+}
+
+func (s saved) Load(w *world.World) {
+	s.Entity.Load(w)
+}
+
+//The parts shared by every entity in this package
+type Base struct {
+	entity.EntityComponent
+	entity.PositionComponent
+	entity.LastPositionComponent
+	entity.VelocityComponent
+
+	//Sending a channel locks the entity until the channel is closed.
+	//Lockers should also wait on ClosedChannel as the entity stops
+	//taking locks once it has been removed.
+	LockChan chan chan struct{} `msgpack:"ignore"`
+	//Closed once the entity has been removed or unloaded
+	ClosedChannel chan struct{} `msgpack:"ignore"`
+
+	remove chan struct{}
+	unload chan struct{}
+}
+
+func (b *Base) base() *Base {
+	return b
+}
+
+func (Base) Saveable() bool {
+	return true
+}
+
+func (b *Base) DespawnPackets() []protocol.Packet {
+	return []protocol.Packet{
+		protocol.EntityDestroy{[]int32{b.ID}},
+	}
+}
+
+//Despawns the entity and removes it from its world. Safe to call from
+//any goroutine.
+func (b *Base) Remove() {
+	select {
+	case b.remove <- struct{}{}:
+	default:
+	}
+}
+
+//Stops the entity, keeping it saved with its chunk. Called by the
+//chunk when it is unloaded.
+func (b *Base) Unload() {
+	select {
+	case b.unload <- struct{}{}:
+	default:
+	}
+}
+
+//Spawns the entity into the world at the position and starts its
+//goroutine. The entity must not be changed afterwards other than from
+//its goroutine or whilst it is locked with LockChan.
+func Spawn(e Entity, w *world.World, x, y, z float64) {
+	b := e.base()
+	b.World = w
+	b.ID = entity.GetID()
+	if b.Uuid == "" {
+		b.Uuid = entity.NewUUID()
+	}
+	b.X, b.Y, b.Z = x, y, z
+	b.UpdateChunk()
+	b.LastCX, b.LastCZ = b.CX, b.CZ
+	b.MarkSent(b.Position())
+	b.LockChan = make(chan chan struct{})
+	b.ClosedChannel = make(chan struct{})
+	b.remove = make(chan struct{}, 1)
+	b.unload = make(chan struct{}, 1)
+	e.prepare()
+	b.Init(e)
+
+	entity.Register(b.ID, e)
+	w.AddEntity(int(b.CX), int(b.CZ), e)
+	w.TrackEntity(e, b.X, b.Z, e.SpawnPackets(), e.DespawnPackets())
+	go run(e)
+}
+
+func run(e Entity) {
+	b := e.base()
+	tick := time.NewTicker(time.Second / ticksPerSecond)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			b.Update(e)
+			e.tick()
+			if b.Y < voidLevel {
+				b.Remove()
+			}
+			if b.CurrentTick%saveInterval == 0 {
+				b.World.AddEntity(int(b.CX), int(b.CZ), e)
+			}
+		case lock := <-b.LockChan:
+			<-lock
+		case <-b.remove:
+			despawn(e, false)
+			return
+		case <-b.unload:
+			despawn(e, true)
+			return
+		}
+	}
+}
+
+func despawn(e Entity, unload bool) {
+	b := e.base()
+	b.World.UntrackEntity(e)
+	if unload {
+		b.World.UnloadEntity(int(b.CX), int(b.CZ), e)
+	} else {
+		b.World.RemoveEntity(int(b.CX), int(b.CZ), e)
+	}
+	entity.Unregister(b.ID)
+	entity.FreeID(b.ID)
+	close(b.ClosedChannel)
+}
+
+//Returns the entity's position in the fixed point format used by spawn
+//packets
+func (b *Base) fixedPosition() (x, y, z int32) {
+	return entity.FixedPoint(b.X), entity.FixedPoint(b.Y), entity.FixedPoint(b.Z)
+}
+
+//Returns the entity's velocity in the protocol's format
+func (b *Base) packedVelocity() (x, y, z int16) {
+	return entity.PackVelocity(b.VX), entity.PackVelocity(b.VY), entity.PackVelocity(b.VZ)
+}
@@ -0,0 +1,97 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package generic
+
+import (
+	"github.com/NetherrackDev/netherrack/entity"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"github.com/NetherrackDev/netherrack/world"
+)
+
+const (
+	objectItem = 2
+	//Metadata index of the item's stack
+	metadataItem = 10
+	//Ticks before dropped items disappear
+	itemLifetime = ticksPerSecond * 60 * 5
+)
+
+//A dropped item stack
+type Item struct {
+	Base
+	entity.MetadataComponent
+
+	Item protocol.Slot
+	//Ticks since the item was dropped
+	Age int32
+}
+
+//Returns a dropped item for the stack ready to be spawned
+func NewItem(item protocol.Slot) *Item {
+	i := &Item{Item: item}
+	i.Gravity, i.Drag = 16, 0.4
+	return i
+}
+
+func (i *Item) prepare() {
+	i.Set(metadataItem, i.Item)
+}
+
+func (i *Item) tick() {
+	i.Age++
+	if i.Age >= itemLifetime {
+		i.Remove()
+	}
+}
+
+func (i *Item) SpawnPackets() []protocol.Packet {
+	x, y, z := i.fixedPosition()
+	vx, vy, vz := i.packedVelocity()
+	return []protocol.Packet{
+		protocol.SpawnObject{
+			EntityID:  protocol.VarInt(i.ID),
+			Type:      objectItem,
+			X:         x,
+			Y:         y,
+			Z:         z,
+			Pitch:     entity.PackAngle(i.Pitch),
+			Yaw:       entity.PackAngle(i.Yaw),
+			ExtraData: 1,
+			SpeedX:    vx,
+			SpeedY:    vy,
+			SpeedZ:    vz,
+		},
+		//The client doesn't know what the item is until it gets
+		//its metadata
+		protocol.EntityMetadata{
+			EntityID: i.ID,
+			Metadata: i.All(),
+		},
+	}
+}
+
+func (i *Item) TrackingKind() world.TrackingKind {
+	return world.TrackItem
+}
+
+func (i *Item) Snapshot() world.Entity {
+	return saved{i.Uuid, *i}
+}
+
+func (i Item) Load(w *world.World) {
+	go Spawn(&i, w, i.X, i.Y, i.Z)
+}
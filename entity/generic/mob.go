@@ -0,0 +1,112 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package generic
+
+import (
+	"github.com/NetherrackDev/netherrack/entity"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"github.com/NetherrackDev/netherrack/world"
+)
+
+//The type of mob the client shows
+type MobType byte
+
+const (
+	MobCreeper     MobType = 50
+	MobSkeleton    MobType = 51
+	MobSpider      MobType = 52
+	MobZombie      MobType = 54
+	MobSlime       MobType = 55
+	MobEnderman    MobType = 58
+	MobBlaze       MobType = 61
+	MobEnderDragon MobType = 63
+	MobBat         MobType = 65
+	MobWitch       MobType = 66
+	MobPig         MobType = 90
+	MobSheep       MobType = 91
+	MobCow         MobType = 92
+	MobChicken     MobType = 93
+	MobSquid       MobType = 94
+	MobWolf        MobType = 95
+	MobMooshroom   MobType = 96
+	MobSnowGolem   MobType = 97
+	MobOcelot      MobType = 98
+	MobIronGolem   MobType = 99
+	MobHorse       MobType = 100
+	MobVillager    MobType = 120
+)
+
+//A mob without any behaviour of its own. Plugins move it by locking it
+//and changing its position or velocity.
+type Mob struct {
+	Base
+	entity.LivingMetadataComponent
+
+	Type MobType
+	//The mob's name tag, saved from its metadata
+	Name string
+}
+
+//Returns a mob of the type ready to be spawned
+func NewMob(ty MobType) *Mob {
+	m := &Mob{Type: ty}
+	m.Gravity, m.Drag = 32, 0.4
+	return m
+}
+
+func (m *Mob) prepare() {
+	if m.Name != "" {
+		m.SetCustomName(m.Name, false)
+	}
+}
+
+func (m *Mob) tick() {}
+
+func (m *Mob) SpawnPackets() []protocol.Packet {
+	x, y, z := m.fixedPosition()
+	vx, vy, vz := m.packedVelocity()
+	return []protocol.Packet{
+		protocol.SpawnMob{
+			EntityID:  protocol.VarInt(m.ID),
+			Type:      byte(m.Type),
+			X:         x,
+			Y:         y,
+			Z:         z,
+			Pitch:     entity.PackAngle(m.Pitch),
+			HeadPitch: entity.PackAngle(m.Pitch),
+			Yaw:       entity.PackAngle(m.Yaw),
+			VelocityX: vx,
+			VelocityY: vy,
+			VelocityZ: vz,
+			Metadata:  m.All(),
+		},
+	}
+}
+
+func (m *Mob) TrackingKind() world.TrackingKind {
+	return world.TrackMob
+}
+
+func (m *Mob) Snapshot() world.Entity {
+	s := *m
+	s.Name = m.CustomName()
+	return saved{m.Uuid, s}
+}
+
+func (m Mob) Load(w *world.World) {
+	go Spawn(&m, w, m.X, m.Y, m.Z)
+}
@@ -0,0 +1,350 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package entity
+
+import (
+	"github.com/NetherrackDev/netherrack/blocks"
+	"github.com/NetherrackDev/netherrack/message"
+	"math"
+)
+
+const (
+	//Entities are updated 10 times a second
+	ticksPerSecond = 10
+	//Ticks after being hurt before the entity can be hurt again
+	hurtCooldown = ticksPerSecond / 2
+	//Ticks between healing or starving
+	foodTimer = ticksPerSecond * 4
+	//Blocks an entity can fall without taking damage
+	safeFall = 3
+	//Height below which entities take void damage
+	voidLevel = -64
+)
+
+//The cause of damage to an entity
+type DamageType int
+
+const (
+	DamageGeneric DamageType = iota
+	DamageFall
+	DamageVoid
+	DamageAttack
+	DamageLava
+	DamageStarve
+)
+
+//Describes what damaged an entity
+type DamageSource struct {
+	Type DamageType
+	//The name of the attacking entity for DamageAttack
+	Attacker string
+}
+
+//Returns the message shown when the named entity is killed by the source
+func (d DamageSource) DeathMessage(name string) *message.Message {
+	with := []*message.Message{{Text: name}}
+	var key string
+	switch d.Type {
+	case DamageFall:
+		key = "death.fell.accident.generic"
+	case DamageVoid:
+		key = "death.attack.outOfWorld"
+	case DamageAttack:
+		key = "death.attack.player"
+		with = append(with, &message.Message{Text: d.Attacker})
+	case DamageLava:
+		key = "death.attack.lava"
+	case DamageStarve:
+		key = "death.attack.starve"
+	default:
+		key = "death.attack.generic"
+	}
+	return &message.Message{Translate: key, With: with}
+}
+
+type HealthComponent struct {
+	Current, Max float32
+	//What last hurt the entity
+	LastDamage DamageSource
+	//Set whenever the entity is hurt. Cleared by the entity once it has
+	//shown the damage
+	Hurt bool
+
+	cooldown int
+	timer    int
+}
+
+func (h *HealthComponent) Health() *HealthComponent {
+	return h
+}
+
+//Returns whether the entity has no health left
+func (h *HealthComponent) Dead() bool {
+	return h.Current <= 0
+}
+
+type FoodComponent struct {
+	Level      int16
+	Saturation float32
+	//Once this reaches 4 saturation or food is used up
+	Exhaustion float32
+
+	lastX, lastZ float64
+	moved        bool
+}
+
+func (f *FoodComponent) Food() *FoodComponent {
+	return f
+}
+
+type FallComponent struct {
+	OnGround     bool
+	FallDistance float64
+
+	lastY  float64
+	tracks bool
+}
+
+func (f *FallComponent) Fall() *FallComponent {
+	return f
+}
+
+//Forgets the current fall, used when the entity is moved instead
+//of falling
+func (f *FallComponent) Reset(y float64) {
+	f.FallDistance = 0
+	f.lastY = y
+	f.tracks = true
+}
+
+type damageable interface {
+	Health() *HealthComponent
+}
+
+//Optionally implemented by entities that can stop themselves being hurt
+type invulnerable interface {
+	Invulnerable() bool
+}
+
+//Hurts the entity by the amount if it has health and isn't invulnerable.
+//Returns whether the entity was hurt. Must be called from the entity's
+//goroutine.
+func Damage(entity interface{}, source DamageSource, amount float32) bool {
+	d, ok := entity.(damageable)
+	if !ok {
+		return false
+	}
+	h := d.Health()
+	if h.Dead() || h.cooldown > 0 || amount <= 0 {
+		return false
+	}
+	//Nothing protects from the void
+	if inv, ok := entity.(invulnerable); ok && inv.Invulnerable() && source.Type != DamageVoid {
+		return false
+	}
+	h.Current -= amount
+	if h.Current < 0 {
+		h.Current = 0
+	}
+	h.cooldown = hurtCooldown
+	h.LastDamage = source
+	h.Hurt = true
+	if f, ok := entity.(hungry); ok {
+		f.Food().Exhaustion += 0.3
+	}
+	return true
+}
+
+func init() {
+	RegisterSystem(SystemHealth{})
+	RegisterSystem(SystemEnvironment{})
+	RegisterSystem(SystemHunger{})
+}
+
+//Heals entities that are well fed and starves ones that aren't
+type SystemHealth struct{}
+
+func (SystemHealth) Valid(e interface{}) bool {
+	_, ok := e.(damageable)
+	return ok
+}
+
+func (SystemHealth) Priority() Priority { return High }
+
+func (SystemHealth) Update(entity interface{}) {
+	h := entity.(damageable).Health()
+	if h.Dead() {
+		return
+	}
+	if h.cooldown > 0 {
+		h.cooldown--
+	}
+	f, ok := entity.(hungry)
+	if !ok {
+		return
+	}
+	food := f.Food()
+	switch {
+	case food.Level >= 18 && h.Current < h.Max:
+		h.timer++
+		if h.timer >= foodTimer {
+			h.timer = 0
+			h.Current++
+			if h.Current > h.Max {
+				h.Current = h.Max
+			}
+			food.Exhaustion += 3
+		}
+	case food.Level <= 0:
+		h.timer++
+		if h.timer >= foodTimer {
+			h.timer = 0
+			//Starving leaves the entity on half a heart
+			if h.Current > 1 {
+				Damage(entity, DamageSource{Type: DamageStarve}, 1)
+			}
+		}
+	default:
+		h.timer = 0
+	}
+}
+
+//Hurts entities that fall too far, fall out of the world or touch lava
+type SystemEnvironment struct{}
+
+type environmental interface {
+	damageable
+	Entity() *EntityComponent
+	Position() *PositionComponent
+	Fall() *FallComponent
+}
+
+//Optionally implemented by entities that can fly
+type flyer interface {
+	Flying() bool
+}
+
+func (SystemEnvironment) Valid(e interface{}) bool {
+	_, ok := e.(environmental)
+	return ok
+}
+
+func (SystemEnvironment) Priority() Priority { return High }
+
+func (SystemEnvironment) Update(entity interface{}) {
+	env := entity.(environmental)
+	if env.Health().Dead() {
+		return
+	}
+	e := env.Entity()
+	p := env.Position()
+	f := env.Fall()
+	x, y, z := int(math.Floor(p.X)), int(math.Floor(p.Y)), int(math.Floor(p.Z))
+	feet, head := blockAt(e, x, y, z), blockAt(e, x, y+1, z)
+
+	if !f.tracks {
+		f.Reset(p.Y)
+	}
+	dy := p.Y - f.lastY
+	f.lastY = p.Y
+	if fl, ok := entity.(flyer); (ok && fl.Flying()) || isWater(feet) {
+		f.FallDistance = 0
+	} else if dy < 0 {
+		f.FallDistance -= dy
+	}
+	if f.OnGround {
+		if f.FallDistance > safeFall {
+			Damage(entity, DamageSource{Type: DamageFall}, float32(math.Ceil(f.FallDistance-safeFall)))
+		}
+		f.FallDistance = 0
+	}
+
+	if p.Y < voidLevel {
+		Damage(entity, DamageSource{Type: DamageVoid}, 4)
+	}
+	if isLava(feet) || isLava(head) {
+		Damage(entity, DamageSource{Type: DamageLava}, 4)
+	}
+}
+
+//Returns the block at the position, treating blocks outside of the
+//world or in chunks that aren't loaded as air
+func blockAt(e *EntityComponent, x, y, z int) byte {
+	if y < 0 || y > 255 {
+		return 0
+	}
+	block, _, _ := e.World.LoadedBlock(x, y, z)
+	return block
+}
+
+func isWater(block byte) bool {
+	return block == blocks.Water.ID || block == blocks.WaterStill.ID
+}
+
+func isLava(block byte) bool {
+	return block == blocks.Lava.ID || block == blocks.LavaStill.ID
+}
+
+//Uses up food as entities move
+type SystemHunger struct{}
+
+type hungry interface {
+	Food() *FoodComponent
+}
+
+type hungryMovable interface {
+	hungry
+	damageable
+	Position() *PositionComponent
+}
+
+func (SystemHunger) Valid(e interface{}) bool {
+	_, ok := e.(hungryMovable)
+	return ok
+}
+
+func (SystemHunger) Priority() Priority { return High }
+
+func (SystemHunger) Update(entity interface{}) {
+	h := entity.(hungryMovable)
+	f := h.Food()
+	p := h.Position()
+	if h.Health().Dead() {
+		f.moved = false
+		return
+	}
+	if f.moved {
+		dx, dz := p.X-f.lastX, p.Z-f.lastZ
+		dist := math.Sqrt(dx*dx + dz*dz)
+		//Longer moves are teleports rather than walking
+		if dist < 10 {
+			f.Exhaustion += float32(dist * 0.01)
+		}
+	}
+	f.lastX, f.lastZ, f.moved = p.X, p.Z, true
+	for f.Exhaustion >= 4 {
+		f.Exhaustion -= 4
+		if f.Saturation > 0 {
+			f.Saturation--
+			if f.Saturation < 0 {
+				f.Saturation = 0
+			}
+		} else if f.Level > 0 {
+			f.Level--
+		}
+	}
+}
@@ -0,0 +1,254 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package entity
+
+import (
+	"errors"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"github.com/NetherrackDev/netherrack/world"
+	"reflect"
+)
+
+//Metadata indices
+const (
+	metadataFlags = 0
+	metadataAir   = 1
+
+	metadataHealth         = 6
+	metadataPotionColor    = 7
+	metadataPotionAmbient  = 8
+	metadataArrows         = 9
+	metadataCustomName     = 10
+	metadataShowCustomName = 11
+
+	//Full air supply in ticks
+	defaultAir = 300
+)
+
+var (
+	ErrorUnsupportedMetadata = errors.New("Unsupported metadata type")
+	ErrorMetadataType        = errors.New("Wrong metadata type for the index")
+)
+
+//Types of the metadata every entity has
+var metadataTypes = map[byte]reflect.Type{
+	metadataFlags: reflect.TypeOf(int8(0)),
+	metadataAir:   reflect.TypeOf(int16(0)),
+}
+
+//Flags stored in an entity's metadata
+type EntityFlag int8
+
+const (
+	FlagOnFire    EntityFlag = 0x01
+	FlagSneaking  EntityFlag = 0x02
+	FlagSprinting EntityFlag = 0x08
+	//Eating, drinking or blocking
+	FlagUsingItem EntityFlag = 0x10
+	FlagInvisible EntityFlag = 0x20
+)
+
+//Metadata shared by every entity. Changes are sent to the players that
+//can see the entity on its next tick.
+//The methods must be called from the entity's goroutine.
+type MetadataComponent struct {
+	values map[byte]interface{}
+	dirty  map[byte]bool
+}
+
+func (m *MetadataComponent) Metadata() *MetadataComponent {
+	return m
+}
+
+func (m *MetadataComponent) init() {
+	if m.values != nil {
+		return
+	}
+	m.values = map[byte]interface{}{
+		metadataFlags: int8(0),
+		metadataAir:   int16(defaultAir),
+	}
+	m.dirty = map[byte]bool{}
+}
+
+//Sets the value at the index, marking it as changed if it differs.
+//Returns ErrorUnsupportedMetadata if the value's type can't be sent
+//in protocol.EntityMetadata and ErrorMetadataType if the index is one
+//every entity has and the value isn't its type.
+func (m *MetadataComponent) Set(index byte, value interface{}) error {
+	if !protocol.IsMetadataType(value) {
+		return ErrorUnsupportedMetadata
+	}
+	if t, ok := metadataTypes[index]; ok && reflect.TypeOf(value) != t {
+		return ErrorMetadataType
+	}
+	m.init()
+	if old, ok := m.values[index]; ok && reflect.DeepEqual(old, value) {
+		return nil
+	}
+	m.values[index] = value
+	m.dirty[index] = true
+	return nil
+}
+
+//Returns the value at the index or nil if it isn't set
+func (m *MetadataComponent) Get(index byte) interface{} {
+	m.init()
+	return m.values[index]
+}
+
+//Returns a copy of all of the entity's metadata, used when spawning it
+func (m *MetadataComponent) All() map[byte]interface{} {
+	m.init()
+	all := make(map[byte]interface{}, len(m.values))
+	for i, v := range m.values {
+		all[i] = v
+	}
+	return all
+}
+
+//Returns the metadata changed since the last call or nil if nothing
+//has changed
+func (m *MetadataComponent) Changes() map[byte]interface{} {
+	m.init()
+	if len(m.dirty) == 0 {
+		return nil
+	}
+	changes := make(map[byte]interface{}, len(m.dirty))
+	for i := range m.dirty {
+		changes[i] = m.values[i]
+	}
+	m.dirty = map[byte]bool{}
+	return changes
+}
+
+//Returns whether the flag is set
+func (m *MetadataComponent) Flag(flag EntityFlag) bool {
+	return m.Get(metadataFlags).(int8)&int8(flag) != 0
+}
+
+//Sets or clears the flag
+func (m *MetadataComponent) SetFlag(flag EntityFlag, set bool) {
+	flags := m.Get(metadataFlags).(int8)
+	if set {
+		flags |= int8(flag)
+	} else {
+		flags &^= int8(flag)
+	}
+	m.Set(metadataFlags, flags)
+}
+
+//Returns the air the entity has left in ticks
+func (m *MetadataComponent) Air() int16 {
+	return m.Get(metadataAir).(int16)
+}
+
+func (m *MetadataComponent) SetAir(air int16) {
+	m.Set(metadataAir, air)
+}
+
+//Metadata for mobs and players
+type LivingMetadataComponent struct {
+	MetadataComponent
+}
+
+//Changes the health shown by the client, this doesn't change the
+//entity's actual health
+func (m *LivingMetadataComponent) SetHealth(health float32) {
+	m.Set(metadataHealth, health)
+}
+
+//Changes the colour of the potion particles around the entity, 0
+//removes them. ambient makes the particles fainter like beacon effects.
+func (m *LivingMetadataComponent) SetPotionColor(color int32, ambient bool) {
+	m.Set(metadataPotionColor, color)
+	var a int8
+	if ambient {
+		a = 1
+	}
+	m.Set(metadataPotionAmbient, a)
+}
+
+//Changes the number of arrows shown stuck in the entity
+func (m *LivingMetadataComponent) SetArrows(arrows int8) {
+	m.Set(metadataArrows, arrows)
+}
+
+//Returns the entity's name tag
+func (m *LivingMetadataComponent) CustomName() string {
+	name, _ := m.Get(metadataCustomName).(string)
+	return name
+}
+
+//Names the entity. If always is set the name is shown even when the
+//entity isn't being looked at.
+func (m *LivingMetadataComponent) SetCustomName(name string, always bool) {
+	m.Set(metadataCustomName, name)
+	var a int8
+	if always {
+		a = 1
+	}
+	m.Set(metadataShowCustomName, a)
+}
+
+func init() {
+	RegisterSystem(SystemMetadata{})
+}
+
+//Sends changes to entities' metadata
+type SystemMetadata struct{}
+
+type metadataEntity interface {
+	world.Entity
+	Entity() *EntityComponent
+	Position() *PositionComponent
+	Metadata() *MetadataComponent
+	SpawnPackets() []protocol.Packet
+}
+
+//Implemented by entities that are also sent their own metadata, e.g.
+//players
+type packetQueuer interface {
+	QueuePacket(packet protocol.Packet)
+}
+
+func (SystemMetadata) Valid(e interface{}) bool {
+	_, ok := e.(metadataEntity)
+	return ok
+}
+
+//Runs after other systems so their changes are sent the same tick
+func (SystemMetadata) Priority() Priority { return Low }
+
+func (SystemMetadata) Update(entity interface{}) {
+	me := entity.(metadataEntity)
+	changes := me.Metadata().Changes()
+	if changes == nil {
+		return
+	}
+	e := me.Entity()
+	packet := protocol.EntityMetadata{
+		EntityID: e.ID,
+		Metadata: changes,
+	}
+	e.World.QueueEntityPacket(me, packet)
+	if q, ok := entity.(packetQueuer); ok {
+		q.QueuePacket(packet)
+	}
+	p := me.Position()
+	e.World.UpdateTrackedEntity(me, p.X, p.Z, me.SpawnPackets())
+}
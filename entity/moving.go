@@ -19,6 +19,7 @@ package entity
 import (
 	"github.com/NetherrackDev/netherrack/protocol"
 	"github.com/NetherrackDev/netherrack/world"
+	"math"
 )
 
 type PositionComponent struct {
@@ -31,17 +32,44 @@ func (p *PositionComponent) Position() *PositionComponent {
 	return p
 }
 
+//Converts a position to the fixed point format used by the protocol
+func FixedPoint(v float64) int32 {
+	return int32(math.Floor(v * 32))
+}
+
+//Converts an angle in degrees to the protocol's 256 steps per turn
+func PackAngle(a float32) int8 {
+	return int8(int32(math.Floor(float64(a) / 360 * 256)))
+}
+
+//Updates the chunk position to the chunk the entity is in
+func (p *PositionComponent) UpdateChunk() {
+	p.CX, p.CZ = int32(math.Floor(p.X))>>4, int32(math.Floor(p.Z))>>4
+}
+
 type LastPositionComponent struct {
 	MovedChunk          bool
 	LastCX, LastCZ      int32
 	LastX, LastY, LastZ float64
 	LastYaw, LastPitch  float32
+
+	//The position and look last sent to clients. Moves are sent
+	//relative to these so rounding errors don't build up
+	SentX, SentY, SentZ int32
+	SentYaw, SentPitch  int8
 }
 
 func (lp *LastPositionComponent) LastPosition() *LastPositionComponent {
 	return lp
 }
 
+//Records the position as the one clients last saw, should be called
+//when the entity is spawned with it
+func (lp *LastPositionComponent) MarkSent(p *PositionComponent) {
+	lp.SentX, lp.SentY, lp.SentZ = FixedPoint(p.X), FixedPoint(p.Y), FixedPoint(p.Z)
+	lp.SentYaw, lp.SentPitch = PackAngle(p.Yaw), PackAngle(p.Pitch)
+}
+
 func init() {
 	RegisterSystem(SystemMovable{})
 }
@@ -63,93 +91,86 @@ func (SystemMovable) Valid(e interface{}) bool {
 
 func (SystemMovable) Priority() Priority { return Normal }
 
+func fitsByte(v int32) bool {
+	return v >= math.MinInt8 && v <= math.MaxInt8
+}
+
 //Updates the entity's movement and moves the chunk its in if required
 func (SystemMovable) Update(entity interface{}) {
 	mov := entity.(movable)
 	e := mov.Entity()
 	p := mov.Position()
 	m := mov.LastPosition()
-	p.CX, p.CZ = int32(p.X)>>4, int32(p.Z)>>4
+	p.UpdateChunk()
 	if p.CX != m.LastCX || p.CZ != m.LastCZ {
 		m.MovedChunk = true
-		//TODO: Move the entity to the next chunk
+		e.World.MoveEntity(int(m.LastCX), int(m.LastCZ), int(p.CX), int(p.CZ), mov)
 	}
 	m.LastCX, m.LastCZ = p.CX, p.CZ
 
 	if e.CurrentTick%2 == 0 {
-		moved := false
-		if e.CurrentTick%(10*5) == 0 {
+		x, y, z := FixedPoint(p.X), FixedPoint(p.Y), FixedPoint(p.Z)
+		yaw, pitch := PackAngle(p.Yaw), PackAngle(p.Pitch)
+		dx, dy, dz := x-m.SentX, y-m.SentY, z-m.SentZ
+		moved := dx != 0 || dy != 0 || dz != 0
+		looked := yaw != m.SentYaw || pitch != m.SentPitch
+		switch {
+		//Relative moves are limited to a byte, teleports also correct
+		//clients that missed a move
+		case e.CurrentTick%(10*5) == 0 || !fitsByte(dx) || !fitsByte(dy) || !fitsByte(dz):
 			moved = true
-			e.World.QueuePacket(int(p.CX), int(p.CZ), e.Uuid, protocol.EntityTeleport{
+			e.World.QueueEntityPacket(mov, protocol.EntityTeleport{
 				EntityID: e.ID,
-				X:        int32(p.X * 32),
-				Y:        int32(p.Y * 32),
-				Z:        int32(p.Z * 32),
-				Yaw:      int8((p.Yaw / 360) * 256),
-				Pitch:    int8((p.Pitch / 360) * 256),
+				X:        x,
+				Y:        y,
+				Z:        z,
+				Yaw:      yaw,
+				Pitch:    pitch,
 			})
-		} else {
-			dx := p.X - m.LastX
-			dy := p.Y - m.LastY
-			dz := p.Z - m.LastZ
-			dyaw := p.Yaw - m.LastYaw
-			dpitch := p.Pitch - m.LastPitch
-			if dx >= 4 || dy >= 4 || dz >= 4 {
-				moved = true
-				e.World.QueuePacket(int(p.CX), int(p.CZ), e.Uuid, protocol.EntityTeleport{
-					EntityID: e.ID,
-					X:        int32(p.X * 32),
-					Y:        int32(p.Y * 32),
-					Z:        int32(p.Z * 32),
-					Yaw:      int8((p.Yaw / 360) * 256),
-					Pitch:    int8((p.Pitch / 360) * 256),
-				})
-				e.World.QueuePacket(int(p.CX), int(p.CZ), e.Uuid, protocol.EntityHeadLook{
-					EntityID: e.ID,
-					HeadYaw:  int8((p.Yaw / 360) * 256),
-				})
-			} else if (dx != 0 || dy != 0 || dz != 0) && (dyaw != 0 || dpitch != 0) {
-				moved = true
-				e.World.QueuePacket(int(p.CX), int(p.CZ), e.Uuid, protocol.EntityLookMove{
-					EntityID: e.ID,
-					DX:       int8(dx * 32),
-					DY:       int8(dy * 32),
-					DZ:       int8(dz * 32),
-					Yaw:      int8((p.Yaw / 360) * 256),
-					Pitch:    int8((p.Pitch / 360) * 256),
-				})
-				e.World.QueuePacket(int(p.CX), int(p.CZ), e.Uuid, protocol.EntityHeadLook{
-					EntityID: e.ID,
-					HeadYaw:  int8((p.Yaw / 360) * 256),
-				})
-			} else if dx != 0 || dy != 0 || dz != 0 {
-				moved = true
-				e.World.QueuePacket(int(p.CX), int(p.CZ), e.Uuid, protocol.EntityMove{
-					EntityID: e.ID,
-					DX:       int8(dx * 32),
-					DY:       int8(dy * 32),
-					DZ:       int8(dz * 32),
-				})
-			} else if dyaw != 0 || dpitch != 0 {
-				moved = true
-				e.World.QueuePacket(int(p.CX), int(p.CZ), e.Uuid, protocol.EntityLook{
-					EntityID: e.ID,
-					Yaw:      int8((p.Yaw / 360) * 256),
-					Pitch:    int8((p.Pitch / 360) * 256),
-				})
-				e.World.QueuePacket(int(p.CX), int(p.CZ), e.Uuid, protocol.EntityHeadLook{
+			if looked {
+				e.World.QueueEntityPacket(mov, protocol.EntityHeadLook{
 					EntityID: e.ID,
-					HeadYaw:  int8((p.Yaw / 360) * 256),
+					HeadYaw:  yaw,
 				})
 			}
-			if moved {
-				e.World.UpdateSpawnData(int(p.CX), int(p.CZ), mov, true, mov.SpawnPackets())
-			}
+		case moved && looked:
+			e.World.QueueEntityPacket(mov, protocol.EntityLookMove{
+				EntityID: e.ID,
+				DX:       int8(dx),
+				DY:       int8(dy),
+				DZ:       int8(dz),
+				Yaw:      yaw,
+				Pitch:    pitch,
+			})
+			e.World.QueueEntityPacket(mov, protocol.EntityHeadLook{
+				EntityID: e.ID,
+				HeadYaw:  yaw,
+			})
+		case moved:
+			e.World.QueueEntityPacket(mov, protocol.EntityMove{
+				EntityID: e.ID,
+				DX:       int8(dx),
+				DY:       int8(dy),
+				DZ:       int8(dz),
+			})
+		case looked:
+			e.World.QueueEntityPacket(mov, protocol.EntityLook{
+				EntityID: e.ID,
+				Yaw:      yaw,
+				Pitch:    pitch,
+			})
+			e.World.QueueEntityPacket(mov, protocol.EntityHeadLook{
+				EntityID: e.ID,
+				HeadYaw:  yaw,
+			})
+		}
+		if moved || looked {
+			m.SentX, m.SentY, m.SentZ = x, y, z
+			m.SentYaw, m.SentPitch = yaw, pitch
+			e.World.UpdateTrackedEntity(mov, p.X, p.Z, mov.SpawnPackets())
 		}
 
 		m.LastX, m.LastY, m.LastZ = p.X, p.Y, p.Z
 		m.LastYaw, m.LastPitch = p.Yaw, p.Pitch
 	}
-
-	return
 }
@@ -0,0 +1,59 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package player
+
+import (
+	"github.com/NetherrackDev/netherrack/world"
+)
+
+const (
+	//Most chunks sent in a single MapChunkBulk, keeps the packet well
+	//below the protocol's size limit
+	maxBulkChunks = 10
+)
+
+//Collects the chunk to be sent with the others that are ready this tick
+func (v *worldViewer) QueueChunk(chunk world.RawChunk) {
+	v.Lock()
+	defer v.Unlock()
+	if !v.left {
+		v.chunks = append(v.chunks, chunk)
+	}
+}
+
+//Sends the collected chunks as MapChunkBulk packets followed by the
+//packets held back behind them. Called every tick from the player's
+//goroutine.
+func (v *worldViewer) flush() {
+	v.Lock()
+	defer v.Unlock()
+	if v.left || len(v.chunks) == 0 {
+		return
+	}
+	for chunks := v.chunks; len(chunks) > 0; {
+		n := len(chunks)
+		if n > maxBulkChunks {
+			n = maxBulkChunks
+		}
+		v.p.QueuePacket(v.p.World.BulkPacket(chunks[:n]))
+		chunks = chunks[n:]
+	}
+	for _, packet := range v.held {
+		v.p.QueuePacket(packet)
+	}
+	v.chunks, v.held = nil, nil
+}
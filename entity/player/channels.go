@@ -0,0 +1,138 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package player
+
+import (
+	"bytes"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	//Plugin channel used by clients to list the channels they listen on
+	registerChannel = "REGISTER"
+	//Plugin channel used by clients to stop listening on channels
+	unregisterChannel = "UNREGISTER"
+	//The longest channel name the client supports
+	maxChannelLength = 20
+)
+
+//A ChannelHandler is called on the player's goroutine when the player
+//sends a plugin message on the channel it was registered for
+type ChannelHandler func(p *Player, data []byte)
+
+var channelHandlers = map[string]ChannelHandler{}
+
+//Registers a handler for plugin messages sent on the named channel. The
+//channel is advertised to players with REGISTER when they join.
+//Should only be called at init.
+func RegisterChannel(name string, handler ChannelHandler) {
+	if len(name) > maxChannelLength {
+		panic("Channel name too long: " + name)
+	}
+	if name == registerChannel || name == unregisterChannel {
+		panic("Reserved channel name: " + name)
+	}
+	if _, ok := channelHandlers[name]; ok {
+		panic("Channel already registered: " + name)
+	}
+	channelHandlers[name] = handler
+}
+
+//Tracks the channels the client has registered
+type clientChannels struct {
+	sync.RWMutex
+	m map[string]bool
+}
+
+//Sends the channels the server listens on to the client
+func (p *Player) registerChannels() {
+	if len(channelHandlers) == 0 {
+		return
+	}
+	names := make([]string, 0, len(channelHandlers))
+	for name := range channelHandlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	p.QueuePacket(protocol.PluginMessage{
+		Channel: registerChannel,
+		Data:    []byte(strings.Join(names, "\x00")),
+	})
+}
+
+//Handles a plugin message from the client
+func (p *Player) pluginMessage(packet protocol.ClientPluginMessage) {
+	switch packet.Channel {
+	case registerChannel, unregisterChannel:
+		register := packet.Channel == registerChannel
+		p.channels.Lock()
+		for _, name := range bytes.Split(packet.Data, []byte{0}) {
+			if len(name) == 0 || len(name) > maxChannelLength {
+				continue
+			}
+			if register {
+				p.channels.m[string(name)] = true
+			} else {
+				delete(p.channels.m, string(name))
+			}
+		}
+		p.channels.Unlock()
+	default:
+		if handler, ok := channelHandlers[packet.Channel]; ok {
+			handler(p, packet.Data)
+		}
+	}
+}
+
+//Returns whether the client has registered the channel. Channels
+//starting with MC| are built into the client and are always supported.
+func (p *Player) SupportsChannel(channel string) bool {
+	if strings.HasPrefix(channel, "MC|") {
+		return true
+	}
+	p.channels.RLock()
+	defer p.channels.RUnlock()
+	return p.channels.m[channel]
+}
+
+//Returns the channels the client has registered
+func (p *Player) Channels() []string {
+	p.channels.RLock()
+	defer p.channels.RUnlock()
+	names := make([]string, 0, len(p.channels.m))
+	for name := range p.channels.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//Sends a plugin message to the player if the client has registered the
+//channel. Returns whether the message was sent.
+func (p *Player) SendPluginMessage(channel string, data []byte) bool {
+	if !p.SupportsChannel(channel) {
+		return false
+	}
+	p.QueuePacket(protocol.PluginMessage{
+		Channel: channel,
+		Data:    data,
+	})
+	return true
+}
@@ -0,0 +1,91 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package player
+
+import (
+	"bytes"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegisterChannels(t *testing.T) {
+	p, closePlayer := newTestPlayer(ioutil.Discard)
+	defer closePlayer()
+
+	p.pluginMessage(protocol.ClientPluginMessage{
+		Channel: "REGISTER",
+		Data:    []byte("alpha\x00beta\x00gamma"),
+	})
+	if got, want := p.Channels(), []string{"alpha", "beta", "gamma"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Registered %v, wanted %v", got, want)
+	}
+	p.pluginMessage(protocol.ClientPluginMessage{
+		Channel: "UNREGISTER",
+		Data:    []byte("alpha\x00gamma"),
+	})
+	if got, want := p.Channels(), []string{"beta"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Registered %v after unregistering, wanted %v", got, want)
+	}
+	//Too long for the client to have sent
+	p.pluginMessage(protocol.ClientPluginMessage{
+		Channel: "REGISTER",
+		Data:    []byte("averyveryverylongchannel"),
+	})
+	if got, want := p.Channels(), []string{"beta"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Registered %v after a long name, wanted %v", got, want)
+	}
+}
+
+func TestSendPluginMessage(t *testing.T) {
+	out := &recordingWriter{}
+	p, closePlayer := newTestPlayer(out)
+	defer closePlayer()
+
+	p.pluginMessage(protocol.ClientPluginMessage{
+		Channel: "REGISTER",
+		Data:    []byte("beta"),
+	})
+	if p.SendPluginMessage("alpha", []byte{1}) {
+		t.Fatal("Sent a message on a channel the client didn't register")
+	}
+	if !p.SendPluginMessage("beta", []byte{2}) {
+		t.Fatal("Didn't send a message on a registered channel")
+	}
+	if !p.SendPluginMessage("MC|Brand", []byte{3}) {
+		t.Fatal("Didn't send a message on a built in channel")
+	}
+
+	want := encodePackets(t, []protocol.Packet{
+		protocol.PluginMessage{Channel: "beta", Data: []byte{2}},
+		protocol.PluginMessage{Channel: "MC|Brand", Data: []byte{3}},
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for out.Len() < len(want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Only %d of %d bytes were written", out.Len(), len(want))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	out.Lock()
+	defer out.Unlock()
+	if !bytes.Equal(out.buf.Bytes(), want) {
+		t.Fatalf("Wrote %v, wanted %v", out.buf.Bytes(), want)
+	}
+}
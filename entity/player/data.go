@@ -0,0 +1,99 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package player
+
+import (
+	"github.com/NetherrackDev/netherrack/protocol"
+	"log"
+	"sync/atomic"
+)
+
+const (
+	//Ticks between saving the player's data
+	saveInterval = 60 * 10
+)
+
+//The state of a player that is kept between sessions. It is stored in
+//the server's default world with the key "player-" followed by the
+//player's uuid.
+type savedData struct {
+	World      string
+	X, Y, Z    float64
+	Yaw, Pitch float32
+	GameMode   GameMode
+	Health     float32
+	Food       int16
+	Saturation float32
+	Inventory  []protocol.Slot
+	HeldSlot   int32
+}
+
+func (p *Player) dataKey() string {
+	return "player-" + p.Uuid
+}
+
+//Restores the player's state from their last session. Returns false if
+//the player hasn't played before or their world no longer exists, in
+//which case nothing is changed.
+func (p *Player) loadData() bool {
+	var data savedData
+	if err := p.Server.DefaultWorld().Read(p.dataKey(), &data); err != nil {
+		return false
+	}
+	w := p.Server.World(data.World)
+	if w == nil {
+		return false
+	}
+	p.World = w
+	p.X, p.Y, p.Z = data.X, data.Y, data.Z
+	p.Yaw, p.Pitch = data.Yaw, data.Pitch
+	p.SetGameMode(data.GameMode)
+	p.inventory.inv.SetSlots(data.Inventory)
+	if data.HeldSlot >= 0 && data.HeldSlot <= 8 {
+		atomic.StoreInt32(&p.inventory.held, data.HeldSlot)
+	}
+	//Players that quit whilst dead come back at the spawn
+	if data.Health <= 0 {
+		p.X, p.Y, p.Z = p.spawnPoint()
+		return true
+	}
+	p.HealthComponent.Current = data.Health
+	p.FoodComponent.Level, p.FoodComponent.Saturation = data.Food, data.Saturation
+	return true
+}
+
+//Stores the player's current state so it can be restored when they next
+//join
+func (p *Player) saveData() {
+	data := savedData{
+		World:      p.World.Name,
+		X:          p.X,
+		Y:          p.Y,
+		Z:          p.Z,
+		Yaw:        p.Yaw,
+		Pitch:      p.Pitch,
+		GameMode:   p.GameMode(),
+		Health:     p.HealthComponent.Current,
+		Food:       p.FoodComponent.Level,
+		Saturation: p.FoodComponent.Saturation,
+		Inventory:  p.inventory.inv.Slots(),
+		HeldSlot:   int32(p.HeldSlot()),
+	}
+	if err := p.Server.DefaultWorld().Write(p.dataKey(), &data); err != nil {
+		log.Printf("Failed to save player %s: %s\n", p.Username, err)
+	}
+}
@@ -27,3 +27,16 @@ type PlayerHandler interface {
 	Chat(string)
 	Leave()
 }
+
+//Optionally implemented by a PlayerHandler to handle items the player
+//throws out of their inventory. Thrown items are destroyed otherwise.
+type DropHandler interface {
+	DropItem(item protocol.Slot)
+}
+
+//Optionally implemented by a PlayerHandler to be told when the player's
+//movement is rejected. count is how many times the player has been
+//caught for the violation.
+type MoveHandler interface {
+	IllegalMove(violation Violation, count int)
+}
@@ -0,0 +1,229 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package player
+
+import (
+	"github.com/NetherrackDev/netherrack/protocol"
+	"sync"
+)
+
+//A GameMode controls what a player is allowed to do
+type GameMode byte
+
+const (
+	//Players can take damage and have to break blocks over time
+	Survival GameMode = iota
+	//Players can fly, can't take damage and break blocks instantly
+	Creative
+	//Players can't break or place blocks
+	Adventure
+)
+
+const (
+	//The flag added to the game mode in JoinGame for hardcore worlds
+	hardcoreFlag = 0x8
+	//The reason in GameState for changing the game mode
+	changeGameMode = 3
+
+	invulnerableAbility = 0x1
+	flyingAbility       = 0x2
+	allowFlyingAbility  = 0x4
+	instantBuildAbility = 0x8
+
+	defaultFlyingSpeed  = 0.05
+	defaultWalkingSpeed = 0.1
+)
+
+//The game mode and abilities of the player
+type playerGameMode struct {
+	sync.Mutex
+	mode GameMode
+	//Lets the player fly outside of creative
+	allowFlying bool
+	flying      bool
+	//Whether the client has been sent the JoinGame packet
+	joined bool
+}
+
+//Returns the player's game mode
+func (p *Player) GameMode() GameMode {
+	p.gameMode.Lock()
+	defer p.gameMode.Unlock()
+	return p.gameMode.mode
+}
+
+//Changes the player's game mode. This can be called before the player
+//has joined (e.g. in PlayerJoin).
+func (p *Player) SetGameMode(mode GameMode) {
+	p.gameMode.Lock()
+	defer p.gameMode.Unlock()
+	if p.gameMode.mode == mode {
+		return
+	}
+	p.gameMode.mode = mode
+	if mode != Creative && !p.gameMode.allowFlying {
+		p.gameMode.flying = false
+	}
+	if !p.gameMode.joined {
+		return
+	}
+	p.QueuePacket(protocol.GameState{
+		Reason: changeGameMode,
+		Value:  float32(mode),
+	})
+	p.sendAbilities()
+}
+
+//Controls whether the player can fly when they aren't in creative mode
+func (p *Player) SetAllowFlying(allow bool) {
+	p.gameMode.Lock()
+	defer p.gameMode.Unlock()
+	p.gameMode.allowFlying = allow
+	if !allow && p.gameMode.mode != Creative {
+		p.gameMode.flying = false
+	}
+	if p.gameMode.joined {
+		p.sendAbilities()
+	}
+}
+
+//Returns whether the player is currently flying
+func (p *Player) Flying() bool {
+	p.gameMode.Lock()
+	defer p.gameMode.Unlock()
+	return p.gameMode.flying
+}
+
+//Returns whether the player can't take damage
+func (p *Player) Invulnerable() bool {
+	return p.GameMode() == Creative
+}
+
+//Returns the abilities the player has. The lock must be held
+func (p *Player) abilities() byte {
+	var flags byte
+	if p.gameMode.mode == Creative {
+		flags |= invulnerableAbility | allowFlyingAbility | instantBuildAbility
+	}
+	if p.gameMode.allowFlying {
+		flags |= allowFlyingAbility
+	}
+	if p.gameMode.flying {
+		flags |= flyingAbility
+	}
+	return flags
+}
+
+//The lock must be held
+func (p *Player) sendAbilities() {
+	p.QueuePacket(protocol.PlayerAbilities{
+		Flags:        p.abilities(),
+		FlyingSpeed:  defaultFlyingSpeed,
+		WalkingSpeed: defaultWalkingSpeed,
+	})
+}
+
+//Called once the JoinGame packet has been queued with the game mode
+//that was sent
+func (p *Player) joinGameMode(mode GameMode) {
+	p.gameMode.Lock()
+	defer p.gameMode.Unlock()
+	p.gameMode.mode = mode &^ hardcoreFlag
+	p.gameMode.joined = true
+	p.sendAbilities()
+}
+
+//The client only sends this to start or stop flying
+func (p *Player) clientAbilities(packet protocol.ClientPlayerAbilities) {
+	p.gameMode.Lock()
+	defer p.gameMode.Unlock()
+	flying := packet.Flags&flyingAbility != 0
+	if flying && p.abilities()&allowFlyingAbility == 0 {
+		//Correct the client
+		p.sendAbilities()
+		return
+	}
+	p.gameMode.flying = flying
+}
+
+//Applies the game mode's rules to digging before passing it to the
+//handler
+func (p *Player) blockDig(packet protocol.PlayerDigging) {
+	//Only starting, cancelling and finishing digging are affected
+	if packet.Status > 2 {
+		p.Handler.BlockDig(packet)
+		return
+	}
+	switch p.GameMode() {
+	case Adventure:
+		p.resendBlock(int(packet.X), int(packet.Y), int(packet.Z))
+		return
+	case Creative:
+		//Blocks are broken as soon as creative players start
+		//digging
+		if packet.Status != 0 {
+			return
+		}
+		packet.Status = 2
+	}
+	p.Handler.BlockDig(packet)
+}
+
+//Applies the game mode's rules to block placement before passing it to
+//the handler
+func (p *Player) blockPlacement(packet protocol.PlayerBlockPlacement) {
+	//A direction of -1 is using the held item rather than placing it
+	if p.GameMode() == Adventure && packet.Direction != -1 {
+		//The client has already placed the block on the face it
+		//clicked
+		x, y, z := int(packet.X), int(packet.Y), int(packet.Z)
+		switch packet.Direction {
+		case 0:
+			y--
+		case 1:
+			y++
+		case 2:
+			z--
+		case 3:
+			z++
+		case 4:
+			x--
+		case 5:
+			x++
+		default:
+			return
+		}
+		p.resendBlock(x, y, z)
+		return
+	}
+	p.Handler.BlockPlacement(packet)
+}
+
+//Sends the block to the client to undo a change it predicted
+func (p *Player) resendBlock(x, y, z int) {
+	if y < 0 || y > 255 {
+		return
+	}
+	block, data := p.World.Block(x, y, z)
+	p.QueuePacket(protocol.BlockChange{
+		X:    int32(x),
+		Y:    byte(y),
+		Z:    int32(z),
+		Type: protocol.VarInt(block),
+		Data: data,
+	})
+}
@@ -0,0 +1,210 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package player
+
+import (
+	"github.com/NetherrackDev/netherrack/entity"
+	"github.com/NetherrackDev/netherrack/inventory"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"github.com/NetherrackDev/netherrack/world"
+	"math"
+)
+
+const (
+	maxHealth         = 20
+	maxFood           = 20
+	defaultSaturation = 5
+
+	//EntityStatus values
+	statusHurt = 2
+	statusDead = 3
+
+	//The payload of ClientStatuses when the player clicks respawn
+	performRespawn = 0
+
+	//Furthest away a player can attack from
+	maxReach = 6
+	//Speed a player is knocked away from their attacker at in blocks
+	//per second
+	knockback = 8
+)
+
+//Damage dealt by weapons, other items do 1
+var attackDamage = map[int16]float32{
+	268: 5, 283: 5, 272: 6, 267: 7, 276: 8, //Swords
+	271: 4, 286: 4, 275: 5, 258: 6, 279: 7, //Axes
+}
+
+//The health of the player as last sent to the client
+type playerHealth struct {
+	damage     chan damageRequest
+	dead       bool
+	health     float32
+	food       int16
+	saturation float32
+}
+
+type damageRequest struct {
+	source entity.DamageSource
+	amount float32
+	//Set when the damage is from another player
+	from *attacker
+}
+
+//Where the attacker was when they attacked
+type attacker struct {
+	world   *world.World
+	x, y, z float64
+}
+
+func (p *Player) initHealth() {
+	p.HealthComponent.Current = maxHealth
+	p.HealthComponent.Max = maxHealth
+	p.FoodComponent.Level = maxFood
+	p.FoodComponent.Saturation = defaultSaturation
+	p.health.damage = make(chan damageRequest, 20)
+	p.health.health = -1
+}
+
+//Hurts the player unless they are invulnerable. This is safe to call
+//from any goroutine.
+func (p *Player) Damage(source entity.DamageSource, amount float32) {
+	select {
+	case p.health.damage <- damageRequest{source: source, amount: amount}:
+	case <-p.ClosedChannel:
+	}
+}
+
+//Applies damage on the player's goroutine
+func (p *Player) takeDamage(d damageRequest) {
+	if d.from == nil {
+		entity.Damage(p, d.source, d.amount)
+		return
+	}
+	if d.from.world != p.World {
+		return
+	}
+	dx, dy, dz := p.X-d.from.x, p.Y-d.from.y, p.Z-d.from.z
+	if dx*dx+dy*dy+dz*dz > maxReach*maxReach || !entity.Damage(p, d.source, d.amount) {
+		return
+	}
+	//Knock the player away from the attacker
+	var vx, vz float64
+	if dist := math.Sqrt(dx*dx + dz*dz); dist > 0 {
+		vx, vz = dx/dist*knockback, dz/dist*knockback
+	}
+	packet := protocol.EntityVelocity{
+		EntityID:  p.ID,
+		VelocityX: entity.PackVelocity(vx),
+		VelocityY: entity.PackVelocity(knockback),
+		VelocityZ: entity.PackVelocity(vz),
+	}
+	//The player's client moves them, everyone else sees the knockback
+	//from the velocity
+	p.QueuePacket(packet)
+	p.World.QueueEntityPacket(p, packet)
+}
+
+//Attacks the entity the player hit. Only players can be attacked
+//currently.
+func (p *Player) attack(id int32) {
+	target, ok := entity.ByID(id).(*Player)
+	if !ok || target == p || p.Dead() {
+		return
+	}
+	board := p.Scoreboard()
+	if team := board.PlayerTeam(p.Username); team != nil && !team.FriendlyFire() &&
+		team == board.PlayerTeam(target.Username) {
+		return
+	}
+	amount, ok := attackDamage[p.HeldItem().ID]
+	if !ok {
+		amount = 1
+	}
+	d := damageRequest{
+		source: entity.DamageSource{Type: entity.DamageAttack, Attacker: p.Username},
+		amount: amount,
+		from:   &attacker{p.World, p.X, p.Y, p.Z},
+	}
+	//Attacks are dropped rather than waiting on a busy player
+	select {
+	case target.health.damage <- d:
+	default:
+	}
+}
+
+//Shows changes to the player's health and kills them once it has
+//run out. Called every tick.
+func (p *Player) updateHealth() {
+	h, f := p.Health(), p.Food()
+	if h.Hurt {
+		h.Hurt = false
+		p.World.QueueEntityPacket(p, protocol.EntityStatus{
+			EntityID: p.ID,
+			Status:   statusHurt,
+		})
+	}
+	if h.Current != p.health.health || f.Level != p.health.food || f.Saturation != p.health.saturation {
+		p.sendHealth()
+	}
+	if h.Dead() && !p.health.dead {
+		p.die()
+	}
+}
+
+func (p *Player) sendHealth() {
+	h, f := p.Health(), p.Food()
+	p.health.health, p.health.food, p.health.saturation = h.Current, f.Level, f.Saturation
+	p.QueuePacket(protocol.UpdateHealth{
+		Health:         h.Current,
+		Food:           f.Level,
+		FoodSaturation: f.Saturation,
+	})
+}
+
+//Announces the death and throws the player's items. The client shows
+//the death screen once it is sent no health.
+func (p *Player) die() {
+	p.health.dead = true
+	p.World.QueueEntityPacket(p, protocol.EntityStatus{
+		EntityID: p.ID,
+		Status:   statusDead,
+	})
+	p.Server.SendMessage(p.Health().LastDamage.DeathMessage(p.Username))
+	p.CloseWindow()
+	p.returnCursor()
+	//The crafting output isn't a real item
+	for _, item := range p.inventory.inv.Slots()[inventory.CraftingGrid:] {
+		p.dropItem(item)
+	}
+	p.inventory.inv.Clear()
+}
+
+//Brings a dead player back at the world's spawn
+func (p *Player) respawn() {
+	if !p.health.dead {
+		return
+	}
+	p.health.dead = false
+	h, f := p.Health(), p.Food()
+	h.Current, h.Hurt = h.Max, false
+	f.Level, f.Saturation, f.Exhaustion = maxFood, defaultSaturation, 0
+
+	p.sendRespawn()
+	x, y, z := p.spawnPoint()
+	p.Teleport(p.World, x, y, z, p.Yaw, p.Pitch)
+}
@@ -0,0 +1,114 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package player
+
+import (
+	"github.com/NetherrackDev/netherrack/protocol"
+	"sync"
+)
+
+//An Interceptor is called for packets travelling between a player and the
+//server. The packet is passed on by calling next. Calling next with a
+//different packet rewrites it, not calling next drops it and calling it
+//multiple times injects extra packets.
+//
+//Inbound (protocol.Serverbound) interceptors run on the player's goroutine
+//before the packet is processed. Outbound (protocol.Clientbound)
+//interceptors run on the player's writer goroutine before the packet is
+//written to the connection.
+type Interceptor func(p *Player, packet protocol.Packet, next func(protocol.Packet))
+
+var globalInterceptors [2][]Interceptor
+
+//Adds an interceptor that will be used for every player. Global
+//interceptors run before a player's own interceptors in the order they
+//were added. Should only be called at init.
+func AddGlobalInterceptor(direction protocol.Direction, i Interceptor) {
+	globalInterceptors[direction] = append(globalInterceptors[direction], i)
+}
+
+type interceptorEntry struct {
+	id          int
+	interceptor Interceptor
+}
+
+type interceptors struct {
+	sync.RWMutex
+	lastID int
+	chains [2][]interceptorEntry
+}
+
+//Adds an interceptor to the player. Interceptors run in the order they
+//were added after the global interceptors. The returned function removes
+//the interceptor. This is safe to call from any goroutine.
+func (p *Player) AddInterceptor(direction protocol.Direction, i Interceptor) (remove func()) {
+	p.interceptors.Lock()
+	defer p.interceptors.Unlock()
+	p.interceptors.lastID++
+	id := p.interceptors.lastID
+	//Copy on write so running chains aren't affected
+	old := p.interceptors.chains[direction]
+	chain := make([]interceptorEntry, len(old), len(old)+1)
+	copy(chain, old)
+	p.interceptors.chains[direction] = append(chain, interceptorEntry{id, i})
+	return func() {
+		p.interceptors.Lock()
+		defer p.interceptors.Unlock()
+		old := p.interceptors.chains[direction]
+		chain := make([]interceptorEntry, 0, len(old))
+		for _, e := range old {
+			if e.id != id {
+				chain = append(chain, e)
+			}
+		}
+		p.interceptors.chains[direction] = chain
+	}
+}
+
+//Passes the packet through the interceptors for the direction before
+//handing it to final
+func (p *Player) intercept(direction protocol.Direction, packet protocol.Packet, final func(protocol.Packet)) {
+	global := globalInterceptors[direction]
+	p.interceptors.RLock()
+	local := p.interceptors.chains[direction]
+	p.interceptors.RUnlock()
+	if len(global) == 0 && len(local) == 0 {
+		final(packet)
+		return
+	}
+	p.runGlobal(global, local, packet, final)
+}
+
+func (p *Player) runGlobal(global []Interceptor, local []interceptorEntry, packet protocol.Packet, final func(protocol.Packet)) {
+	if len(global) == 0 {
+		p.runLocal(local, packet, final)
+		return
+	}
+	global[0](p, packet, func(packet protocol.Packet) {
+		p.runGlobal(global[1:], local, packet, final)
+	})
+}
+
+func (p *Player) runLocal(local []interceptorEntry, packet protocol.Packet, final func(protocol.Packet)) {
+	if len(local) == 0 {
+		final(packet)
+		return
+	}
+	local[0].interceptor(p, packet, func(packet protocol.Packet) {
+		p.runLocal(local[1:], packet, final)
+	})
+}
@@ -0,0 +1,109 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package player
+
+import (
+	"github.com/NetherrackDev/netherrack/protocol"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+//Passes the packets through the player's serverbound interceptors and
+//returns what came out
+func interceptAll(p *Player, packets ...protocol.Packet) []protocol.Packet {
+	var out []protocol.Packet
+	for _, packet := range packets {
+		p.intercept(protocol.Serverbound, packet, func(packet protocol.Packet) {
+			out = append(out, packet)
+		})
+	}
+	return out
+}
+
+func TestInterceptors(t *testing.T) {
+	p, closePlayer := newTestPlayer(ioutil.Discard)
+	defer closePlayer()
+
+	var order []string
+	//Rewrites keep alives
+	p.AddInterceptor(protocol.Serverbound, func(p *Player, packet protocol.Packet, next func(protocol.Packet)) {
+		order = append(order, "rewrite")
+		if ka, ok := packet.(protocol.ClientKeepAlive); ok {
+			ka.KeepAliveID++
+			packet = ka
+		}
+		next(packet)
+	})
+	//Drops chat
+	p.AddInterceptor(protocol.Serverbound, func(p *Player, packet protocol.Packet, next func(protocol.Packet)) {
+		order = append(order, "drop")
+		if _, ok := packet.(protocol.ChatMessage); ok {
+			return
+		}
+		next(packet)
+	})
+	//Injects a held item change after every keep alive
+	p.AddInterceptor(protocol.Serverbound, func(p *Player, packet protocol.Packet, next func(protocol.Packet)) {
+		order = append(order, "inject")
+		next(packet)
+		if _, ok := packet.(protocol.ClientKeepAlive); ok {
+			next(protocol.ClientHeldItemChange{SlotID: 3})
+		}
+	})
+
+	got := interceptAll(p, protocol.ClientKeepAlive{1}, protocol.ChatMessage{"hello"})
+	want := []protocol.Packet{
+		protocol.ClientKeepAlive{2},
+		protocol.ClientHeldItemChange{SlotID: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got %#v, wanted %#v", got, want)
+	}
+	wantOrder := []string{"rewrite", "drop", "inject", "rewrite", "drop"}
+	if !reflect.DeepEqual(order, wantOrder) {
+		t.Fatalf("Ran in the order %v, wanted %v", order, wantOrder)
+	}
+}
+
+func TestGlobalInterceptorsFirst(t *testing.T) {
+	p, closePlayer := newTestPlayer(ioutil.Discard)
+	defer closePlayer()
+
+	var order []string
+	old := globalInterceptors[protocol.Serverbound]
+	defer func() { globalInterceptors[protocol.Serverbound] = old }()
+	remove := p.AddInterceptor(protocol.Serverbound, func(p *Player, packet protocol.Packet, next func(protocol.Packet)) {
+		order = append(order, "local")
+		next(packet)
+	})
+	AddGlobalInterceptor(protocol.Serverbound, func(p *Player, packet protocol.Packet, next func(protocol.Packet)) {
+		order = append(order, "global")
+		next(packet)
+	})
+
+	interceptAll(p, protocol.ClientKeepAlive{1})
+	if want := []string{"global", "local"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("Ran in the order %v, wanted %v", order, want)
+	}
+	order = nil
+	remove()
+	interceptAll(p, protocol.ClientKeepAlive{1})
+	if want := []string{"global"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("Ran %v after removing the local interceptor, wanted %v", order, want)
+	}
+}
@@ -0,0 +1,297 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package player
+
+import (
+	"github.com/NetherrackDev/netherrack/inventory"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"sync/atomic"
+)
+
+const (
+	//The window id of the player's own inventory
+	playerWindow = 0
+	//The window id and slot used to set the item on the cursor
+	cursorWindow = 255
+	cursorSlot   = -1
+)
+
+//The player's own inventory and the state of their clicks
+type playerInventory struct {
+	inv     *inventory.Inventory
+	window  *inventory.Window
+	clicker *inventory.Clicker
+	//The hotbar slot (0-8) being held. Accessed atomically
+	held int32
+	//Clicks are ignored after one is rejected until the client
+	//acknowledges it
+	rejected       bool
+	rejectedWindow int8
+	rejectedAction int16
+	//The held item and armor last sent to other players
+	equipment [5]protocol.Slot
+}
+
+func (p *Player) initInventory() {
+	p.inventory.inv = inventory.NewPlayer()
+	p.inventory.window = inventory.NewPlayerWindow(p.inventory.inv)
+	p.inventory.clicker = inventory.NewClicker(p)
+	p.inventory.clicker.Drop = p.dropItem
+	for i := range p.inventory.equipment {
+		p.inventory.equipment[i] = inventory.Empty
+	}
+}
+
+//Returns the player's inventory. The slots are laid out like the client's
+//inventory window (see the inventory package's constants).
+func (p *Player) Inventory() *inventory.Inventory {
+	return p.inventory.inv
+}
+
+//Returns the hotbar slot (0-8) the player is holding
+func (p *Player) HeldSlot() int {
+	return int(atomic.LoadInt32(&p.inventory.held))
+}
+
+//Changes the hotbar slot (0-8) the player is holding
+func (p *Player) SetHeldSlot(slot int) {
+	if slot < 0 || slot > 8 {
+		return
+	}
+	atomic.StoreInt32(&p.inventory.held, int32(slot))
+	p.QueuePacket(protocol.HeldItemChange{SlotID: byte(slot)})
+}
+
+//Returns the item the player is holding
+func (p *Player) HeldItem() protocol.Slot {
+	return p.inventory.inv.Slot(inventory.Hotbar + p.HeldSlot())
+}
+
+//Adds the item to the player's inventory, filling the hotbar first.
+//Returns the items that didn't fit.
+func (p *Player) GiveItem(item protocol.Slot) protocol.Slot {
+	return p.inventory.inv.AddTo(item, inventory.PlayerAddOrder())
+}
+
+//Sends changes to the player's inventory or open container to the client
+func (p *Player) SlotChanged(inv *inventory.Inventory, slot int, item protocol.Slot) {
+	if inv != p.inventory.inv {
+		p.containerSlotChanged(inv, slot, item)
+		return
+	}
+	p.QueuePacket(protocol.WindowSetSlot{
+		WindowID: playerWindow,
+		Slot:     int16(slot),
+		Item:     item,
+	})
+}
+
+//Sends the player's full inventory or open container to the client
+func (p *Player) InventoryReset(inv *inventory.Inventory, slots []protocol.Slot) {
+	if inv != p.inventory.inv {
+		p.containerReset(inv, slots)
+		return
+	}
+	p.QueuePacket(protocol.WindowItems{
+		WindowID: playerWindow,
+		Slots:    slots,
+	})
+}
+
+//Sends the inventory to the client once they have joined
+func (p *Player) showInventory() {
+	p.inventory.inv.AddWatcher(p)
+	p.QueuePacket(protocol.HeldItemChange{SlotID: byte(p.HeldSlot())})
+	p.inventory.equipment = p.equipment()
+}
+
+func (p *Player) hideInventory() {
+	p.closeContainer()
+	p.inventory.inv.RemoveWatcher(p)
+}
+
+//Sends the full window and cursor again after the client got out of
+//sync
+func (p *Player) resyncWindow(id int8, window *inventory.Window) {
+	if id == playerWindow {
+		p.inventory.inv.Resync(p)
+	} else {
+		p.QueuePacket(protocol.WindowItems{
+			WindowID: byte(id),
+			Slots:    window.Slots(),
+		})
+	}
+	p.QueuePacket(protocol.WindowSetSlot{
+		WindowID: cursorWindow,
+		Slot:     cursorSlot,
+		Item:     p.inventory.clicker.Cursor,
+	})
+}
+
+func (p *Player) heldItemChange(packet protocol.ClientHeldItemChange) {
+	if packet.SlotID < 0 || packet.SlotID > 8 {
+		return
+	}
+	atomic.StoreInt32(&p.inventory.held, int32(packet.SlotID))
+}
+
+func (p *Player) windowClick(packet protocol.WindowClick) {
+	window, container := p.windowByID(packet.WindowID)
+	if window == nil || p.inventory.rejected {
+		return
+	}
+	ok := container == nil || container.OnClick == nil || container.OnClick(p, packet)
+	if ok {
+		p.inventory.clicker.Creative = p.GameMode() == Creative
+		ok = window.Click(p.inventory.clicker, packet)
+	}
+	p.QueuePacket(protocol.WindowTransactionConfirm{
+		WindowID:     byte(packet.WindowID),
+		ActionNumber: packet.ActionNumber,
+		Accepted:     ok,
+	})
+	if !ok {
+		p.inventory.rejected = true
+		p.inventory.rejectedWindow = packet.WindowID
+		p.inventory.rejectedAction = packet.ActionNumber
+		p.resyncWindow(packet.WindowID, window)
+	}
+}
+
+//The client acknowledges rejected clicks before sending more
+func (p *Player) transactionConfirm(packet protocol.ClientWindowTransactionConfirm) {
+	if packet.WindowID == p.inventory.rejectedWindow && packet.ActionNumber == p.inventory.rejectedAction {
+		p.inventory.rejected = false
+	}
+}
+
+func (p *Player) windowClose(packet protocol.ClientWindowClose) {
+	if packet.WindowID != playerWindow {
+		if _, container := p.windowByID(packet.WindowID); container != nil {
+			p.closeContainer()
+		}
+		return
+	}
+	//Items left in the crafting grid are put back into the inventory
+	//or thrown if there isn't space
+	for i := inventory.CraftingGrid; i < inventory.Armor; i++ {
+		item := p.inventory.inv.Take(i, 64)
+		p.dropItem(p.GiveItem(item))
+	}
+	p.returnCursor()
+}
+
+//Puts the item on the cursor back into the inventory or throws it if
+//there isn't space
+func (p *Player) returnCursor() {
+	cursor := p.inventory.clicker.Cursor
+	p.inventory.clicker.Cursor = inventory.Empty
+	p.dropItem(p.GiveItem(cursor))
+}
+
+//Creative players can put any item in their inventory or throw it
+func (p *Player) creativeInventoryAction(packet protocol.CreativeInventoryAction) {
+	if packet.Slot == cursorSlot {
+		if p.GameMode() == Creative && packet.Item.Count <= inventory.MaxStack(packet.Item) {
+			p.dropItem(packet.Item)
+		}
+		return
+	}
+	if packet.Slot < 0 || int(packet.Slot) >= inventory.PlayerSize {
+		return
+	}
+	slot := int(packet.Slot)
+	if p.GameMode() == Creative && slot != inventory.CraftingOutput &&
+		packet.Item.Count <= inventory.MaxStack(packet.Item) {
+		p.inventory.inv.SetSlot(slot, packet.Item)
+		return
+	}
+	//Undo the client's change
+	p.QueuePacket(protocol.WindowSetSlot{
+		WindowID: playerWindow,
+		Slot:     packet.Slot,
+		Item:     p.inventory.inv.Slot(slot),
+	})
+}
+
+//Throws one or all of the held items
+func (p *Player) dropHeld(all bool) {
+	count := int8(1)
+	if all {
+		count = 64
+	}
+	p.dropItem(p.inventory.inv.Take(inventory.Hotbar+p.HeldSlot(), count))
+}
+
+//Passes thrown items to the handler
+func (p *Player) dropItem(item protocol.Slot) {
+	if inventory.IsEmpty(item) {
+		return
+	}
+	if h, ok := p.Handler.(DropHandler); ok {
+		h.DropItem(item)
+	}
+}
+
+//Returns the held item followed by the armor from boots to helmet in
+//the order used by EntityEquipment
+func (p *Player) equipment() (equipment [5]protocol.Slot) {
+	slots := p.inventory.inv.Slots()
+	equipment[0] = slots[inventory.Hotbar+p.HeldSlot()]
+	for i := 1; i < 5; i++ {
+		equipment[i] = slots[inventory.Main-i]
+	}
+	return
+}
+
+//Sends changes to the player's held item and armor to other players
+func (p *Player) updateEquipment() {
+	equipment := p.equipment()
+	changed := false
+	for i, item := range equipment {
+		last := p.inventory.equipment[i]
+		if inventory.Equal(item, last) && inventory.Stackable(item, last) {
+			continue
+		}
+		changed = true
+		p.World.QueueEntityPacket(p, protocol.EntityEquipment{
+			EntityID: p.ID,
+			Slot:     int16(i),
+			Item:     item,
+		})
+	}
+	p.inventory.equipment = equipment
+	if changed {
+		p.World.UpdateTrackedEntity(p, p.X, p.Z, p.SpawnPackets())
+	}
+}
+
+//Returns the packets that show the player's equipment to other players
+func (p *Player) equipmentPackets() []protocol.Packet {
+	var packets []protocol.Packet
+	for i, item := range p.inventory.equipment {
+		if inventory.IsEmpty(item) {
+			continue
+		}
+		packets = append(packets, protocol.EntityEquipment{
+			EntityID: p.ID,
+			Slot:     int16(i),
+			Item:     item,
+		})
+	}
+	return packets
+}
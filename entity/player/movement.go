@@ -0,0 +1,302 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package player
+
+import (
+	"github.com/NetherrackDev/netherrack/blocks"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"math"
+	"time"
+)
+
+const (
+	//How often the client sends its position
+	clientTick = time.Second / 20
+	//Furthest a player can move horizontally each client tick. These are
+	//well above what the client does so lag doesn't cause false positives
+	walkSpeed = 1.0
+	flySpeed  = 2.5
+	//Ticks of unused movement a player can save up, covers packets
+	//bunching up after lag
+	burstTicks = 10
+	//Furthest a player can move up or down in one packet
+	maxVertical = 10
+	//Highest a player can get above where they left the ground
+	maxJump = 2
+	//Packets a player can send whilst in the air without falling
+	maxHover = 10
+	//Limits of the distance between the player's feet and eyes
+	minStance, maxStance = 0.1, 1.65
+	//Height of a standing player's eyes. The client treats the Y of
+	//PlayerPositionLook as its eye height.
+	eyeHeight = 1.62
+	//How far the client's confirmation of a move can be from where it
+	//was sent, covers the client's rounding
+	teleportEpsilon = 0.01
+	//Players can't move beyond this on the x or z axis
+	worldLimit = 30000000
+
+	playerWidth  = 0.6
+	playerHeight = 1.8
+	//Distance between positions checked for blocks along a move
+	collisionStep = 0.25
+	//Shrinks the player so touching a block isn't being inside it
+	collisionMargin = 0.001
+)
+
+//The reason a player's movement was rejected
+type Violation int
+
+const (
+	//The position or look wasn't a number or was outside of the world
+	ViolationInvalid Violation = iota
+	//The player moved further than they could have
+	ViolationSpeed
+	//The player rose or stayed in the air without being able to fly
+	ViolationFlying
+	//The player moved into or through solid blocks
+	ViolationCollision
+
+	violationCount
+)
+
+func (v Violation) String() string {
+	switch v {
+	case ViolationInvalid:
+		return "invalid"
+	case ViolationSpeed:
+		return "speed"
+	case ViolationFlying:
+		return "flying"
+	case ViolationCollision:
+		return "collision"
+	}
+	return "unknown"
+}
+
+//Blocks players can climb or swim up
+var climbable = map[byte]bool{
+	blocks.Water.ID:      true,
+	blocks.WaterStill.ID: true,
+	blocks.Lava.ID:       true,
+	blocks.LavaStill.ID:  true,
+	blocks.Ladders.ID:    true,
+	blocks.Vines.ID:      true,
+	blocks.Cobweb.ID:     true,
+}
+
+//What is known about the player's movement to check new moves against
+type playerMovement struct {
+	unchecked bool
+	last      time.Time
+	//Horizontal distance the player can still move
+	budget float64
+	//Height the player was last on the ground at
+	groundY    float64
+	hover      int
+	violations [violationCount]int
+}
+
+//Controls whether the player's movement is checked for cheating. Checks
+//are on by default, invalid positions are always rejected. This can be
+//called before the player has joined (e.g. in PlayerJoin), afterwards
+//it must be called from the player's goroutine (e.g. from its handler)
+//or whilst the player is locked with LockChan.
+func (p *Player) SetMovementChecks(check bool) {
+	p.movement.unchecked = !check
+}
+
+//Returns how many times the player's movement has been rejected for the
+//violation.
+//Must be called from the player's goroutine (e.g. from its handler) or
+//whilst the player is locked with LockChan.
+func (p *Player) Violations(v Violation) int {
+	return p.movement.violations[v]
+}
+
+//Forgets the player's past movement once the server has moved them
+func (p *Player) resetMovement() {
+	m := &p.movement
+	m.last = time.Now()
+	m.budget = 0
+	m.groundY = p.Y
+	m.hover = 0
+}
+
+//Checks the player could have moved from their current position to the
+//new one, moving them back if they couldn't. Returns whether the move
+//is allowed and whether the player is on the ground. The client's own
+//idea of whether it is on the ground isn't trusted.
+func (p *Player) checkMove(x, y, stance, z float64) (ground, ok bool) {
+	if !validNumber(x, y, z, stance) || math.Abs(x) > worldLimit || math.Abs(z) > worldLimit ||
+		stance-y < minStance || stance-y > maxStance {
+		p.rejectMove(ViolationInvalid)
+		return false, false
+	}
+	m := &p.movement
+	if m.unchecked {
+		return p.supported(x, y, z), true
+	}
+	flying := p.Flying()
+	speed := walkSpeed
+	if flying {
+		speed = flySpeed
+	}
+	now := time.Now()
+	m.budget = math.Min(m.budget+float64(now.Sub(m.last))/float64(clientTick)*speed, burstTicks*speed)
+	m.last = now
+
+	dx, dy, dz := x-p.X, y-p.Y, z-p.Z
+	dist := math.Sqrt(dx*dx + dz*dz)
+	if dist > m.budget || math.Abs(dy) > maxVertical {
+		p.rejectMove(ViolationSpeed)
+		return false, false
+	}
+	m.budget -= dist
+	if p.collides(p.X, p.Y, p.Z, x, y, z) {
+		p.rejectMove(ViolationCollision)
+		return false, false
+	}
+
+	ground = p.supported(x, y, z)
+	if flying || ground || p.climbing(x, y, z) {
+		m.groundY, m.hover = y, 0
+		return ground, true
+	}
+	if dy >= 0 {
+		m.hover++
+	} else {
+		m.hover = 0
+	}
+	if y-m.groundY > maxJump || m.hover > maxHover {
+		p.rejectMove(ViolationFlying)
+		return false, false
+	}
+	return false, true
+}
+
+//Checks the direction the player is looking in is usable
+func (p *Player) checkLook(yaw, pitch float32) bool {
+	if !validNumber(float64(yaw), float64(pitch)) {
+		p.rejectMove(ViolationInvalid)
+		return false
+	}
+	return true
+}
+
+//Moves the player back to their last accepted position and tells the
+//handler about the violation
+func (p *Player) rejectMove(v Violation) {
+	p.movement.violations[v]++
+	p.teleporting = true
+	p.QueuePacket(protocol.PlayerPositionLook{
+		X:        p.X,
+		Y:        p.Y + eyeHeight,
+		Z:        p.Z,
+		Yaw:      p.Yaw,
+		Pitch:    p.Pitch,
+		OnGround: p.OnGround,
+	})
+	if handler, ok := p.Handler.(MoveHandler); ok {
+		handler.IllegalMove(v, p.movement.violations[v])
+	}
+}
+
+func validNumber(values ...float64) bool {
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+//Returns whether the player hits a solid block moving between the two
+//positions. Blocks the player was already inside are ignored so they
+//can get out of them.
+func (p *Player) collides(fx, fy, fz, tx, ty, tz float64) bool {
+	inside := map[[3]int]bool{}
+	playerBlocks(fx, fy, fz, 0, playerHeight, func(b [3]int) bool {
+		inside[b] = true
+		return false
+	})
+	dx, dy, dz := tx-fx, ty-fy, tz-fz
+	steps := int(math.Ceil(math.Sqrt(dx*dx+dy*dy+dz*dz) / collisionStep))
+	for i := 1; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		hit := playerBlocks(fx+dx*t, fy+dy*t, fz+dz*t, 0, playerHeight, func(b [3]int) bool {
+			if inside[b] {
+				return false
+			}
+			inside[b] = true
+			block, ok := p.blockAt(b)
+			return !ok || blocks.Blocks[block].Solid
+		})
+		if hit {
+			return true
+		}
+	}
+	return false
+}
+
+//Returns whether there is a block under the player to stand on. Fences
+//and walls stick half a block out of the top of the block they are in.
+func (p *Player) supported(x, y, z float64) bool {
+	return playerBlocks(x, y, z, -0.6, 0, func(b [3]int) bool {
+		block, ok := p.blockAt(b)
+		return ok && block != blocks.Air.ID
+	})
+}
+
+//Returns whether the player is in something they can move up through
+func (p *Player) climbing(x, y, z float64) bool {
+	return playerBlocks(x, y, z, 0, playerHeight, func(b [3]int) bool {
+		block, ok := p.blockAt(b)
+		return ok && climbable[block]
+	})
+}
+
+//Returns the block at the position, blocks outside of the world are
+//air. ok is false if the block's chunk isn't loaded, checking movement
+//shouldn't wait for chunks to load.
+func (p *Player) blockAt(b [3]int) (block byte, ok bool) {
+	if b[1] < 0 || b[1] > 255 {
+		return blocks.Air.ID, true
+	}
+	block, _, ok = p.World.LoadedBlock(b[0], b[1], b[2])
+	return block, ok
+}
+
+//Calls f with each block the player overlaps between the heights
+//relative to their feet until it returns true. Returns whether f
+//returned true.
+func playerBlocks(x, y, z, bottom, top float64, f func(b [3]int) bool) bool {
+	half := playerWidth/2 - collisionMargin
+	minX, maxX := int(math.Floor(x-half)), int(math.Floor(x+half))
+	minY, maxY := int(math.Floor(y+bottom+collisionMargin)), int(math.Floor(y+top-collisionMargin))
+	minZ, maxZ := int(math.Floor(z-half)), int(math.Floor(z+half))
+	for bx := minX; bx <= maxX; bx++ {
+		for by := minY; by <= maxY; by++ {
+			for bz := minZ; bz <= maxZ; bz++ {
+				if f([3]int{bx, by, bz}) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
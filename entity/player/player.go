@@ -17,14 +17,17 @@
 package player
 
 import (
+	"bufio"
 	"errors"
 	"github.com/NetherrackDev/netherrack/entity"
 	"github.com/NetherrackDev/netherrack/message"
 	"github.com/NetherrackDev/netherrack/protocol"
+	"github.com/NetherrackDev/netherrack/scoreboard"
 	"github.com/NetherrackDev/netherrack/world"
 	"log"
 	"math"
 	"math/rand"
+	"sync/atomic"
 	"time"
 )
 
@@ -39,14 +42,43 @@ type Server interface {
 	QueuePacket(packet protocol.Packet)
 	//Sends the message to every player on the server
 	SendMessage(msg *message.Message)
+	//Updates the ping shown for the player in the player list
+	UpdateListPing(p *Player)
+	//Returns the scoreboard shared by every player on the server
+	Scoreboard() *scoreboard.Scoreboard
+	//Returns the radius in chunks players can see in worlds without
+	//their own view distance
+	ViewDistance() int
 }
 
+const (
+	//Size of the buffer packets are written into before being
+	//sent to the client
+	writeBufferSize = 16 * 1024
+	//Sent in JoinGame and Respawn
+	difficulty = 0
+	levelType  = "default"
+	//The longest a written packet will wait in the buffer whilst
+	//more packets are queued
+	flushDeadline = 50 * time.Millisecond
+
+	//EntityAction actions
+	actionCrouch         = 1
+	actionUncrouch       = 2
+	actionStartSprinting = 4
+	actionStopSprinting  = 5
+)
+
 //A local player is a player connected directly to this server
 type Player struct {
 	entity.EntityComponent
 	//Parts
 	entity.PositionComponent
 	entity.LastPositionComponent
+	entity.HealthComponent
+	entity.FoodComponent
+	entity.FallComponent
+	entity.LivingMetadataComponent
 
 	conn     *protocol.Conn
 	uuid     string
@@ -57,12 +89,32 @@ type Player struct {
 	readPackets   chan protocol.Packet
 	errorChannel  chan error
 	ClosedChannel chan struct{}
+	writerDone    chan struct{}
 
-	rand   *rand.Rand
-	pingID int32
+	rand      *rand.Rand
+	pingID    int32
+	pingSent  time.Time
+	hasPinged bool
+	//Smoothed round trip time of keep alives in nanoseconds.
+	//Accessed atomically
+	ping int64
 
 	Handler PlayerHandler
 
+	interceptors interceptors
+	channels     clientChannels
+	scoreboard   playerScoreboard
+	inventory    playerInventory
+	window       openWindow
+	gameMode     playerGameMode
+	health       playerHealth
+
+	//Set whilst the client hasn't confirmed being moved by the server
+	teleporting bool
+	movement    playerMovement
+	viewer      *worldViewer
+	view        playerView
+
 	LockChan chan chan struct{}
 
 	permission map[string]bool
@@ -76,6 +128,7 @@ func NewPlayer(uuid, username string, conn *protocol.Conn, server Server) *Playe
 		readPackets:   make(chan protocol.Packet, 20),
 		errorChannel:  make(chan error, 1),
 		ClosedChannel: make(chan struct{}),
+		writerDone:    make(chan struct{}),
 		Server:        server,
 		rand:          rand.New(rand.NewSource(time.Now().UnixNano())),
 		LockChan:      make(chan chan struct{}),
@@ -85,7 +138,14 @@ func NewPlayer(uuid, username string, conn *protocol.Conn, server Server) *Playe
 	p.ID = entity.GetID()
 	p.Uuid = uuid
 	p.pingID = -1
+	p.channels.m = map[string]bool{}
+	p.initInventory()
+	p.initHealth()
+	p.viewer = &worldViewer{p: p}
+	p.initView()
 	p.Init(p)
+	//Packets are coalesced into larger writes by the packetWriter
+	conn.Out = bufio.NewWriterSize(conn.Out, writeBufferSize)
 	go p.packetReader()
 	go p.packetWriter()
 	return p
@@ -100,10 +160,12 @@ func (p *Player) SendMessage(msg *message.Message) {
 	p.QueuePacket(protocol.ServerMessage{msg.JSONString()})
 }
 
-//Queues a packet to be sent to the player
+//Queues a packet to be sent to the player. Packets queued after the
+//connection has failed are dropped.
 func (p *Player) QueuePacket(packet protocol.Packet) {
 	select {
 	case p.packetQueue <- packet:
+	case <-p.writerDone:
 	case <-p.ClosedChannel:
 	}
 }
@@ -122,29 +184,41 @@ func (p *Player) CanCall(command string) bool {
 func (p *Player) Start() {
 	defer p.close()
 
-	p.World = p.Server.DefaultWorld()
-	p.X, p.Y, p.Z = 0, 70, 0
+	if !p.loadData() {
+		p.World = p.Server.DefaultWorld()
+		p.X, p.Y, p.Z = p.spawnPoint()
+	}
+	p.UpdateChunk()
+	p.LastCX, p.LastCZ = p.CX, p.CZ
 
 	login := &protocol.JoinGame{
 		EntityID:   p.ID,
-		Gamemode:   0,
+		Gamemode:   byte(p.GameMode()),
 		Dimension:  int8(p.World.Dimension()),
-		Difficulty: 0,
+		Difficulty: difficulty,
 		MaxPlayers: 0,
-		LevelType:  "default",
+		LevelType:  levelType,
 	}
 
 	p.Handler.EnterWorld(login)
 	defer p.Handler.Leave()
 
 	p.QueuePacket(*login)
+	p.joinGameMode(GameMode(login.Gamemode))
+	p.sendSpawnPosition()
 	p.QueuePacket(protocol.PluginMessage{
 		Channel: "MC|Brand",
 		Data:    []byte("Netherrack"),
 	})
+	p.registerChannels()
+	p.showScoreboard()
+	defer p.hideScoreboard()
+	p.showInventory()
+	defer p.hideInventory()
+	p.teleporting = true
 	p.QueuePacket(protocol.PlayerPositionLook{
 		X:        p.X,
-		Y:        p.Y,
+		Y:        p.Y + eyeHeight,
 		Z:        p.Z,
 		Yaw:      p.Yaw,
 		Pitch:    p.Pitch,
@@ -152,12 +226,9 @@ func (p *Player) Start() {
 	})
 	p.spawn()
 	defer p.despawn()
+	entity.Register(p.ID, p)
 
-	for x := -10; x <= 10; x++ {
-		for z := -10; z <= 10; z++ {
-			p.World.JoinChunk(x, z, p)
-		}
-	}
+	p.joinView()
 
 	tick := time.NewTicker(time.Second / 10)
 	defer tick.Stop()
@@ -172,64 +243,91 @@ func (p *Player) Start() {
 					TimeOfDay: -16000, //p.World.TimeOfDay(),
 				})
 			}
+			if p.CurrentTick%saveInterval == 0 && p.CurrentTick != 0 {
+				p.saveData()
+			}
 			if p.CurrentTick%(15*10) == 0 { //Every 15 seconds
 				if p.pingID != -1 {
 					p.disconnect("Timed out")
 					continue
 				}
 				p.pingID = p.rand.Int31()
+				p.pingSent = time.Now()
 				p.QueuePacket(protocol.KeepAlive{p.pingID})
 			}
-			lcx, lcz := p.LastCX, p.LastCZ
+			p.updateViewDistance()
 			p.Update(p)
+			p.updateEquipment()
+			p.updateHealth()
 			if p.MovedChunk {
 				p.MovedChunk = false
-				for x := lcx - 10; x <= lcx+10; x++ {
-					for z := lcz - 10; z <= lcz+10; z++ {
-						if x < p.CX-10 || x > p.CX+10 || z < p.CZ-10 || z > p.CZ+10 {
-							p.World.LeaveChunk(int(x), int(z), p)
-						}
-					}
-				}
-				for x := p.CX - 10; x <= p.CX+10; x++ {
-					for z := p.CZ - 10; z <= p.CZ+10; z++ {
-						if x < lcx-10 || x > lcx+10 || z < lcz-10 || z > lcz+10 {
-							p.World.JoinChunk(int(x), int(z), p)
-						}
-					}
-				}
+				p.updateView()
 			}
+			p.sendChunks()
+			p.updateTracking()
+			p.viewer.flush()
+		case d := <-p.health.damage:
+			p.takeDamage(d)
 		case packet := <-p.readPackets:
-			p.processPacket(packet)
+			p.intercept(protocol.Serverbound, packet, p.processPacket)
 		case lock := <-p.LockChan:
 			<-lock
 		}
 	}
 }
 
+//Returns where players start and respawn in the player's world. The
+//height is moved if blocks have been placed on the spawn since it was
+//picked.
+func (p *Player) spawnPoint() (x, y, z float64) {
+	sx, sy, sz := p.World.Spawn()
+	if safe, ok := p.World.SafeHeight(sx, sz); ok {
+		sy = safe
+	}
+	//The centre of the block
+	return float64(sx) + 0.5, float64(sy), float64(sz) + 0.5
+}
+
+//Tells the client where the world's spawn is, compasses point to it
+func (p *Player) sendSpawnPosition() {
+	x, y, z := p.World.Spawn()
+	p.QueuePacket(protocol.SpawnPosition{
+		X: int32(x),
+		Y: int32(y),
+		Z: int32(z),
+	})
+}
+
 func (p *Player) spawn() {
-	p.World.AddEntity(int(p.CX), int(p.CZ), p, p.SpawnPackets(), p.DespawnPackets())
+	p.MarkSent(p.Position())
+	p.World.AddEntity(int(p.CX), int(p.CZ), p)
+	p.World.TrackEntity(p, p.X, p.Z, p.SpawnPackets(), p.DespawnPackets())
 }
 
 func (p *Player) despawn() {
 	p.World.RemoveEntity(int(p.CX), int(p.CZ), p)
+	p.World.UntrackEntity(p)
 }
 
 func (p *Player) SpawnPackets() []protocol.Packet {
-	return []protocol.Packet{
+	held := p.inventory.equipment[0].ID
+	if held < 0 {
+		held = 0
+	}
+	return append([]protocol.Packet{
 		protocol.SpawnPlayer{
 			EntityID:    protocol.VarInt(p.ID),
 			PlayerName:  p.Username,
 			PlayerUUID:  p.Uuid,
-			X:           int32(p.X * 32),
-			Y:           int32(p.Y * 32),
-			Z:           int32(p.Z * 32),
-			Yaw:         int8((p.Yaw / 360) * 256),
-			Pitch:       int8((p.Pitch / 360) * 256),
-			CurrentItem: 0,
-			Metadata:    map[byte]interface{}{0: int8(0)},
+			X:           entity.FixedPoint(p.X),
+			Y:           entity.FixedPoint(p.Y),
+			Z:           entity.FixedPoint(p.Z),
+			Yaw:         entity.PackAngle(p.Yaw),
+			Pitch:       entity.PackAngle(p.Pitch),
+			CurrentItem: held,
+			Metadata:    p.All(),
 		},
-	}
+	}, p.equipmentPackets()...)
 }
 
 func (p *Player) DespawnPackets() []protocol.Packet {
@@ -242,6 +340,10 @@ func (p *Player) Saveable() bool {
 	return false
 }
 
+func (p *Player) TrackingKind() world.TrackingKind {
+	return world.TrackPlayer
+}
+
 //Acts on the passed packet
 //TODO: Kick player on wrong packet
 func (p *Player) processPacket(packet protocol.Packet) {
@@ -249,11 +351,28 @@ func (p *Player) processPacket(packet protocol.Packet) {
 	case protocol.ChatMessage:
 		p.Handler.Chat(packet.Message)
 	case protocol.PlayerDigging:
-		p.Handler.BlockDig(packet)
+		switch packet.Status {
+		case 3: //Drop stack
+			p.dropHeld(true)
+		case 4: //Drop item
+			p.dropHeld(false)
+		default:
+			p.blockDig(packet)
+		}
 	case protocol.PlayerBlockPlacement:
-		p.Handler.BlockPlacement(packet)
+		p.blockPlacement(packet)
+	case protocol.UseEntity:
+		//Left clicking attacks
+		if packet.Mouse == 1 {
+			p.attack(packet.Target)
+		}
 	case protocol.ClientPlayer:
+		p.OnGround = p.supported(p.X, p.Y, p.Z)
 	case protocol.ClientPlayerLook:
+		if !p.checkLook(packet.Yaw, packet.Pitch) {
+			return
+		}
+		p.OnGround = p.supported(p.X, p.Y, p.Z)
 		yaw := math.Mod(float64(packet.Yaw), 360)
 		if yaw < 0 {
 			yaw = 360 + yaw
@@ -261,15 +380,58 @@ func (p *Player) processPacket(packet protocol.Packet) {
 		p.Yaw = float32(yaw)
 		p.Pitch = packet.Pitch
 	case protocol.ClientPlayerPosition:
+		if !p.acceptMove(packet.X, packet.Stance, packet.Z) {
+			return
+		}
+		onGround, ok := p.checkMove(packet.X, packet.Y, packet.Stance, packet.Z)
+		if !ok {
+			return
+		}
 		p.X, p.Y, p.Z = packet.X, packet.Y, packet.Z
+		p.OnGround = onGround
 	case protocol.ClientPlayerPositionLook:
+		if !p.acceptMove(packet.X, packet.Stance, packet.Z) || !p.checkLook(packet.Yaw, packet.Pitch) {
+			return
+		}
+		onGround, ok := p.checkMove(packet.X, packet.Y, packet.Stance, packet.Z)
+		if !ok {
+			return
+		}
 		p.X, p.Y, p.Z = packet.X, packet.Y, packet.Z
+		p.OnGround = onGround
 		yaw := math.Mod(float64(packet.Yaw), 360)
 		if yaw < 0 {
 			yaw = 360 + yaw
 		}
 		p.Yaw = float32(yaw)
 		p.Pitch = packet.Pitch
+	case protocol.EntityAction:
+		switch packet.ActionID {
+		case actionCrouch, actionUncrouch:
+			p.SetFlag(entity.FlagSneaking, packet.ActionID == actionCrouch)
+		case actionStartSprinting, actionStopSprinting:
+			p.SetFlag(entity.FlagSprinting, packet.ActionID == actionStartSprinting)
+		}
+	case protocol.ClientSettings:
+		p.clientSettings(packet)
+	case protocol.ClientStatuses:
+		if packet.Payload == performRespawn {
+			p.respawn()
+		}
+	case protocol.ClientPluginMessage:
+		p.pluginMessage(packet)
+	case protocol.ClientPlayerAbilities:
+		p.clientAbilities(packet)
+	case protocol.ClientHeldItemChange:
+		p.heldItemChange(packet)
+	case protocol.WindowClick:
+		p.windowClick(packet)
+	case protocol.ClientWindowTransactionConfirm:
+		p.transactionConfirm(packet)
+	case protocol.ClientWindowClose:
+		p.windowClose(packet)
+	case protocol.CreativeInventoryAction:
+		p.creativeInventoryAction(packet)
 	case protocol.ClientKeepAlive:
 		if p.pingID == -1 {
 			return
@@ -279,23 +441,68 @@ func (p *Player) processPacket(packet protocol.Packet) {
 			return
 		}
 		p.pingID = -1
+		p.updatePing(time.Since(p.pingSent))
+	}
+}
+
+//Adds the round trip time to the player's smoothed ping and
+//updates the ping shown in the player list
+func (p *Player) updatePing(rtt time.Duration) {
+	ping := int64(rtt)
+	if p.hasPinged {
+		ping = (atomic.LoadInt64(&p.ping)*3 + ping) / 4
 	}
+	p.hasPinged = true
+	atomic.StoreInt64(&p.ping, ping)
+	p.Server.UpdateListPing(p)
+}
+
+//Returns the player's latency smoothed over recent keep alives.
+//This is safe to call from any goroutine.
+func (p *Player) Ping() time.Duration {
+	return time.Duration(atomic.LoadInt64(&p.ping))
+}
+
+//Disconnects the player with the reason. This is safe to call from any
+//goroutine.
+func (p *Player) Kick(reason string) {
+	p.disconnect(reason)
 }
 
 func (p *Player) disconnect(reason string) {
 	p.QueuePacket(protocol.Disconnect{reason})
-	p.errorChannel <- errors.New(reason)
+	p.reportError(errors.New(reason))
+}
+
+//Passes the error to the player's goroutine which will close the
+//player. Only the first error is kept.
+func (p *Player) reportError(err error) {
+	select {
+	case p.errorChannel <- err:
+	default:
+	}
+}
+
+//Disconnects a player that hasn't been started with the reason and
+//releases its entity id. Blocks until the disconnect message has been
+//written. Start must not be called afterwards.
+func (p *Player) Reject(reason string) {
+	p.QueuePacket(protocol.Disconnect{reason})
+	close(p.ClosedChannel)
+	<-p.writerDone
+	entity.FreeID(p.ID)
 }
 
 //Close and cleanup the player. The packetReader will close
 //once the orginal net.Conn is closed.
 func (p *Player) close() {
 	close(p.ClosedChannel)
-	for x := p.CX - 10; x <= p.CX+10; x++ {
-		for z := p.CZ - 10; z <= p.CZ+10; z++ {
-			p.World.LeaveChunk(int(x), int(z), p)
-		}
-	}
+	//Give the writer a chance to send the remaining packets
+	//(e.g. a disconnect message)
+	<-p.writerDone
+	p.saveData()
+	p.leaveView()
+	entity.Unregister(p.ID)
 	entity.FreeID(p.ID)
 }
 
@@ -304,7 +511,7 @@ func (p *Player) packetReader() {
 	for {
 		packet, err := p.conn.ReadPacket()
 		if err != nil {
-			p.errorChannel <- err
+			p.reportError(err)
 			return
 		}
 		select {
@@ -315,12 +522,43 @@ func (p *Player) packetReader() {
 	}
 }
 
+//Writes queued packets to the connection. Packets are buffered and
+//flushed once the queue is empty or the flushDeadline has passed
+//since the last flush.
 func (p *Player) packetWriter() {
+	defer close(p.writerDone)
+	var err error
+	write := func(packet protocol.Packet) {
+		if err == nil {
+			err = p.conn.WritePacket(packet)
+		}
+	}
+	lastFlush := time.Now()
 	for {
 		select {
 		case packet := <-p.packetQueue:
-			p.conn.WritePacket(packet)
+			p.intercept(protocol.Clientbound, packet, write)
+			if err == nil && (len(p.packetQueue) == 0 || time.Since(lastFlush) >= flushDeadline) {
+				err = p.conn.Flush()
+				lastFlush = time.Now()
+			}
+			if err != nil {
+				p.reportError(err)
+				return
+			}
 		case <-p.ClosedChannel:
+		drain:
+			for {
+				select {
+				case packet := <-p.packetQueue:
+					p.intercept(protocol.Clientbound, packet, write)
+				default:
+					break drain
+				}
+			}
+			if err == nil {
+				p.conn.Flush()
+			}
 			return
 		}
 	}
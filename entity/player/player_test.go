@@ -0,0 +1,200 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package player
+
+import (
+	"bytes"
+	"errors"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errorTestWrite = errors.New("test write failed")
+
+//Records everything written to it
+type recordingWriter struct {
+	sync.Mutex
+	buf    bytes.Buffer
+	writes int
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+	w.writes++
+	return w.buf.Write(b)
+}
+
+func (w *recordingWriter) Len() int {
+	w.Lock()
+	defer w.Unlock()
+	return w.buf.Len()
+}
+
+//Fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write(b []byte) (int, error) {
+	return 0, errorTestWrite
+}
+
+//Returns a player connected to out that hasn't joined a world. The
+//returned function closes the player's connection.
+func newTestPlayer(out io.Writer) (*Player, func()) {
+	in, inWriter := io.Pipe()
+	conn := &protocol.Conn{
+		In:             in,
+		Out:            out,
+		State:          protocol.Play,
+		ReadDirection:  protocol.Serverbound,
+		WriteDirection: protocol.Clientbound,
+	}
+	p := NewPlayer("test", "test", conn, nil)
+	return p, func() {
+		close(p.ClosedChannel)
+		<-p.writerDone
+		inWriter.Close()
+	}
+}
+
+//Returns the packets encoded the same way the player's writer does
+func encodePackets(t *testing.T, packets []protocol.Packet) []byte {
+	var buf bytes.Buffer
+	conn := &protocol.Conn{
+		Out:            &buf,
+		State:          protocol.Play,
+		WriteDirection: protocol.Clientbound,
+	}
+	for _, packet := range packets {
+		if err := conn.WritePacket(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestWriterFlushes(t *testing.T) {
+	out := &recordingWriter{}
+	p, closePlayer := newTestPlayer(out)
+	defer closePlayer()
+
+	packets := []protocol.Packet{
+		protocol.KeepAlive{1},
+		protocol.TimeUpdate{TimeOfDay: 6000},
+		protocol.KeepAlive{2},
+	}
+	for _, packet := range packets {
+		p.QueuePacket(packet)
+	}
+	want := encodePackets(t, packets)
+	//Nothing else is queued so the writer has to flush by itself
+	deadline := time.Now().Add(5 * time.Second)
+	for out.Len() < len(want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Only %d of %d bytes were flushed", out.Len(), len(want))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	out.Lock()
+	defer out.Unlock()
+	if !bytes.Equal(out.buf.Bytes(), want) {
+		t.Fatalf("Wrote %v, wanted %v", out.buf.Bytes(), want)
+	}
+	if out.writes > len(packets) {
+		t.Fatalf("Expected at most %d writes, got %d", len(packets), out.writes)
+	}
+}
+
+func TestWriterReportsErrors(t *testing.T) {
+	p, closePlayer := newTestPlayer(failingWriter{})
+	defer closePlayer()
+
+	p.QueuePacket(protocol.KeepAlive{1})
+	select {
+	case err := <-p.errorChannel:
+		if err != errorTestWrite {
+			t.Fatalf("Expected %v, got %v", errorTestWrite, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write error wasn't reported")
+	}
+}
+
+func TestQueueAfterWriterFailed(t *testing.T) {
+	p, closePlayer := newTestPlayer(failingWriter{})
+	defer closePlayer()
+
+	p.QueuePacket(protocol.KeepAlive{0})
+	select {
+	case <-p.writerDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Writer didn't stop after failing")
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		//More than the queue holds so this would block if nothing
+		//stopped queueing once the writer failed
+		for i := 0; i < cap(p.packetQueue)*2; i++ {
+			p.QueuePacket(protocol.KeepAlive{int32(i)})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("QueuePacket blocked after the writer failed")
+	}
+}
+
+//Records the pings players report for the player list
+type pingServer struct {
+	Server
+	pings []time.Duration
+}
+
+func (s *pingServer) UpdateListPing(p *Player) {
+	s.pings = append(s.pings, p.Ping())
+}
+
+func TestPingSmoothed(t *testing.T) {
+	p, closePlayer := newTestPlayer(ioutil.Discard)
+	defer closePlayer()
+	server := &pingServer{}
+	p.Server = server
+
+	//The first reply is used as is
+	p.pingID, p.pingSent = 5, time.Now().Add(-100*time.Millisecond)
+	p.processPacket(protocol.ClientKeepAlive{5})
+	if ping := p.Ping(); ping < 100*time.Millisecond || ping > time.Second {
+		t.Fatalf("Ping after the first keep alive was %v, wanted about 100ms", ping)
+	}
+	//Later ones are smoothed
+	for _, rtt := range []time.Duration{200 * time.Millisecond, 40 * time.Millisecond} {
+		want := (p.Ping()*3 + rtt) / 4
+		p.updatePing(rtt)
+		if ping := p.Ping(); ping != want {
+			t.Fatalf("Ping after %v was %v, wanted %v", rtt, ping, want)
+		}
+	}
+	if len(server.pings) != 3 || server.pings[2] != p.Ping() {
+		t.Fatalf("Player list was sent %v, wanted 3 updates ending in %v", server.pings, p.Ping())
+	}
+}
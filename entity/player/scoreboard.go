@@ -0,0 +1,80 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package player
+
+import (
+	"github.com/NetherrackDev/netherrack/scoreboard"
+	"sync"
+)
+
+//The scoreboard shown to the player
+type playerScoreboard struct {
+	sync.Mutex
+	board *scoreboard.Scoreboard
+	//Whether the player is currently viewing the board
+	shown bool
+}
+
+//Returns the scoreboard shown to the player. Players are shown the
+//server's scoreboard unless SetScoreboard is used.
+func (p *Player) Scoreboard() *scoreboard.Scoreboard {
+	p.scoreboard.Lock()
+	defer p.scoreboard.Unlock()
+	if p.scoreboard.board == nil {
+		return p.Server.Scoreboard()
+	}
+	return p.scoreboard.board
+}
+
+//Changes the scoreboard shown to the player. This can be called before
+//the player has joined (e.g. in PlayerJoin). Passing nil switches back
+//to the server's scoreboard.
+func (p *Player) SetScoreboard(board *scoreboard.Scoreboard) {
+	p.scoreboard.Lock()
+	defer p.scoreboard.Unlock()
+	if board == nil {
+		board = p.Server.Scoreboard()
+	}
+	old := p.scoreboard.board
+	p.scoreboard.board = board
+	if !p.scoreboard.shown || old == board {
+		return
+	}
+	if old != nil {
+		old.RemoveViewer(p)
+	}
+	board.AddViewer(p)
+}
+
+//Sends the player's scoreboard once they have joined
+func (p *Player) showScoreboard() {
+	p.scoreboard.Lock()
+	defer p.scoreboard.Unlock()
+	if p.scoreboard.board == nil {
+		p.scoreboard.board = p.Server.Scoreboard()
+	}
+	p.scoreboard.shown = true
+	p.scoreboard.board.AddViewer(p)
+}
+
+//Stops keeping the player's scoreboard in sync once they have left
+func (p *Player) hideScoreboard() {
+	p.scoreboard.Lock()
+	defer p.scoreboard.Unlock()
+	p.scoreboard.shown = false
+	p.scoreboard.board.DropViewer(p)
+}
@@ -0,0 +1,155 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package player
+
+import (
+	"github.com/NetherrackDev/netherrack/protocol"
+	"github.com/NetherrackDev/netherrack/world"
+	"math"
+	"sync"
+)
+
+//Receives the packets from the chunks of the world the player is in.
+//Each world gets its own viewer so packets still on their way from the
+//old world's chunks can be dropped once the player has moved on.
+type worldViewer struct {
+	sync.Mutex
+	p    *Player
+	left bool
+
+	//Chunks waiting to be sent together and the packets that arrived
+	//after them
+	chunks []world.RawChunk
+	held   []protocol.Packet
+}
+
+func (v *worldViewer) UUID() string {
+	return v.p.Uuid
+}
+
+func (v *worldViewer) QueuePacket(packet protocol.Packet) {
+	v.Lock()
+	defer v.Unlock()
+	if v.left {
+		return
+	}
+	//Packets can't overtake the chunks they may refer to
+	if len(v.chunks) > 0 {
+		v.held = append(v.held, packet)
+		return
+	}
+	v.p.QueuePacket(packet)
+}
+
+//Stops any more packets being passed on to the player
+func (v *worldViewer) leave() {
+	v.Lock()
+	v.left = true
+	v.chunks, v.held = nil, nil
+	v.Unlock()
+}
+
+//Moves the player to the position in the world. If the world isn't the
+//one the player is in they are moved between them, the client is sent
+//the new world's chunks and its dimension if it differs. Movement from
+//the client is ignored until it confirms the move.
+//Must be called from the player's goroutine (e.g. from its handler) or
+//whilst the player is locked with LockChan.
+func (p *Player) Teleport(w *world.World, x, y, z float64, yaw, pitch float32) {
+	p.despawn()
+	changed := w != p.World
+	if changed {
+		p.leaveView()
+		p.viewer.leave()
+		p.viewer = &worldViewer{p: p}
+		//The client only throws away its world when the dimension
+		//changes so one is faked when it doesn't
+		if w.Dimension() == p.World.Dimension() {
+			other := world.Nether
+			if w.Dimension() == world.Nether {
+				other = world.Overworld
+			}
+			p.QueuePacket(protocol.Respawn{
+				Dimension:  int32(other),
+				Difficulty: difficulty,
+				Gamemode:   byte(p.GameMode()),
+				LevelType:  levelType,
+			})
+		}
+		p.World = w
+		p.sendRespawn()
+	}
+	p.X, p.Y, p.Z = x, y, z
+	p.view.lastX, p.view.lastZ = x, z
+	p.Yaw, p.Pitch = yaw, pitch
+	p.UpdateChunk()
+	p.LastCX, p.LastCZ = p.CX, p.CZ
+	p.LastX, p.LastY, p.LastZ = x, y, z
+	p.LastYaw, p.LastPitch = yaw, pitch
+	p.Fall().Reset(y)
+	if changed {
+		p.joinView()
+	} else {
+		p.updateView()
+		p.sendChunks()
+	}
+	p.spawn()
+	p.teleporting = true
+	p.QueuePacket(protocol.PlayerPositionLook{
+		X:     x,
+		Y:     y + eyeHeight,
+		Z:     z,
+		Yaw:   yaw,
+		Pitch: pitch,
+	})
+}
+
+//Recreates the client's player in the player's world
+func (p *Player) sendRespawn() {
+	p.QueuePacket(protocol.Respawn{
+		Dimension:  int32(p.World.Dimension()),
+		Difficulty: difficulty,
+		Gamemode:   byte(p.GameMode()),
+		LevelType:  levelType,
+	})
+	p.sendSpawnPosition()
+	//The new player doesn't keep any of the old one's state
+	p.gameMode.Lock()
+	p.sendAbilities()
+	p.gameMode.Unlock()
+	p.sendHealth()
+	p.inventory.inv.Resync(p)
+	p.QueuePacket(protocol.HeldItemChange{SlotID: byte(p.HeldSlot())})
+}
+
+//Returns whether the client's movement should be used. Whilst the
+//player is being moved only the client confirming the new position is
+//accepted. The client confirms with the eye height it was sent as its
+//stance and works out its feet from that, so only the stance is
+//compared for the height.
+func (p *Player) acceptMove(x, stance, z float64) bool {
+	if !p.teleporting {
+		return true
+	}
+	if math.Abs(x-p.X) > teleportEpsilon || math.Abs(stance-(p.Y+eyeHeight)) > teleportEpsilon ||
+		math.Abs(z-p.Z) > teleportEpsilon {
+		return false
+	}
+	p.teleporting = false
+	p.resetMovement()
+	return true
+}
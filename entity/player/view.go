@@ -0,0 +1,211 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package player
+
+import (
+	"github.com/NetherrackDev/netherrack/protocol"
+	"math"
+	"sort"
+	"sync/atomic"
+)
+
+const (
+	//Smallest radius in chunks a player can see
+	minViewDistance = 2
+	//Most chunks a player joins each tick
+	chunksPerTick = 20
+	//How much closer chunks in the direction the player is moving are
+	//treated as, as a fraction of their distance
+	movementBias = 0.5
+)
+
+//The chunks the player is sent
+type playerView struct {
+	//Radius in chunks of the chunks the player is watching.
+	//Accessed atomically
+	distance int32
+	//Largest radius the client asked for in its settings
+	client int32
+
+	//Chunks the player is watching
+	joined map[uint64]bool
+	//Chunks in view that are waiting to be joined
+	pending map[uint64]bool
+	//The player's position at the last tick, used to find the
+	//direction they are moving in
+	lastX, lastZ float64
+
+	//The position and distance the world's entity tracker last had
+	trackX, trackZ float64
+	trackDistance  int32
+}
+
+func viewKey(x, z int32) uint64 {
+	return uint64(uint32(x)) | uint64(uint32(z))<<32
+}
+
+func viewPosition(key uint64) (x, z int32) {
+	return int32(uint32(key)), int32(uint32(key >> 32))
+}
+
+func (p *Player) initView() {
+	p.view.joined = map[uint64]bool{}
+	p.view.pending = map[uint64]bool{}
+}
+
+//Returns the radius in chunks the player can currently see. This is
+//safe to call from any goroutine.
+func (p *Player) ViewDistance() int {
+	return int(atomic.LoadInt32(&p.view.distance))
+}
+
+//Returns the radius the player should see, the world's or server's
+//view distance limited to the client's
+func (p *Player) wantedViewDistance() int32 {
+	d := int32(p.World.ViewDistance())
+	if d <= 0 {
+		d = int32(p.Server.ViewDistance())
+	}
+	if p.view.client > 0 && p.view.client < d {
+		d = p.view.client
+	}
+	if d < minViewDistance {
+		d = minViewDistance
+	}
+	return d
+}
+
+func (p *Player) clientSettings(packet protocol.ClientSettings) {
+	p.view.client = int32(packet.ViewDistance)
+	p.updateViewDistance()
+}
+
+//Changes the chunks in view if the view distance has changed. Called
+//every tick to pick up changes to the world or server.
+func (p *Player) updateViewDistance() {
+	d := p.wantedViewDistance()
+	if d == p.view.distance {
+		return
+	}
+	atomic.StoreInt32(&p.view.distance, d)
+	p.updateView()
+}
+
+//Starts viewing the chunks around the player in their world. The
+//closest chunks are joined straight away.
+func (p *Player) joinView() {
+	atomic.StoreInt32(&p.view.distance, p.wantedViewDistance())
+	p.view.lastX, p.view.lastZ = p.X, p.Z
+	p.updateView()
+	p.sendChunks()
+	p.view.trackX, p.view.trackZ, p.view.trackDistance = p.X, p.Z, p.view.distance
+	p.World.AddViewer(p.viewer, p.X, p.Z, int(p.view.distance))
+}
+
+//Leaves every chunk the player is watching
+func (p *Player) leaveView() {
+	p.World.RemoveViewer(p.viewer)
+	for key := range p.view.joined {
+		x, z := viewPosition(key)
+		p.World.LeaveChunk(int(x), int(z), p.viewer)
+	}
+	p.view.joined = map[uint64]bool{}
+	p.view.pending = map[uint64]bool{}
+}
+
+//Leaves the chunks that are no longer in view and queues the new ones
+//to be joined
+func (p *Player) updateView() {
+	d := p.view.distance
+	inView := func(x, z int32) bool {
+		return x >= p.CX-d && x <= p.CX+d && z >= p.CZ-d && z <= p.CZ+d
+	}
+	for key := range p.view.joined {
+		if x, z := viewPosition(key); !inView(x, z) {
+			p.World.LeaveChunk(int(x), int(z), p.viewer)
+			delete(p.view.joined, key)
+		}
+	}
+	for key := range p.view.pending {
+		if x, z := viewPosition(key); !inView(x, z) {
+			delete(p.view.pending, key)
+		}
+	}
+	for x := p.CX - d; x <= p.CX+d; x++ {
+		for z := p.CZ - d; z <= p.CZ+d; z++ {
+			if key := viewKey(x, z); !p.view.joined[key] {
+				p.view.pending[key] = true
+			}
+		}
+	}
+}
+
+type pendingChunk struct {
+	key      uint64
+	priority float64
+}
+
+type chunkSorter []pendingChunk
+
+func (c chunkSorter) Len() int           { return len(c) }
+func (c chunkSorter) Less(i, j int) bool { return c[i].priority < c[j].priority }
+func (c chunkSorter) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+
+//Joins the closest of the pending chunks, favouring the ones in the
+//direction the player is moving. Called every tick.
+func (p *Player) sendChunks() {
+	mx, mz := p.X-p.view.lastX, p.Z-p.view.lastZ
+	p.view.lastX, p.view.lastZ = p.X, p.Z
+	if len(p.view.pending) == 0 {
+		return
+	}
+	speed := math.Sqrt(mx*mx + mz*mz)
+	chunks := make(chunkSorter, 0, len(p.view.pending))
+	for key := range p.view.pending {
+		x, z := viewPosition(key)
+		dx, dz := float64(x-p.CX), float64(z-p.CZ)
+		dist := math.Sqrt(dx*dx + dz*dz)
+		priority := dist
+		if speed > 0 && dist > 0 {
+			//Cosine of the angle between the movement and the chunk
+			ahead := (dx*mx + dz*mz) / (dist * speed)
+			priority -= dist * ahead * movementBias
+		}
+		chunks = append(chunks, pendingChunk{key, priority})
+	}
+	sort.Sort(chunks)
+	if len(chunks) > chunksPerTick {
+		chunks = chunks[:chunksPerTick]
+	}
+	for _, c := range chunks {
+		x, z := viewPosition(c.key)
+		p.World.JoinChunk(int(x), int(z), p.viewer)
+		delete(p.view.pending, c.key)
+		p.view.joined[c.key] = true
+	}
+}
+
+//Tells the world's entity tracker where the player is so they are shown
+//the entities near them. Called every tick.
+func (p *Player) updateTracking() {
+	v := &p.view
+	if v.trackX == p.X && v.trackZ == p.Z && v.trackDistance == v.distance {
+		return
+	}
+	v.trackX, v.trackZ, v.trackDistance = p.X, p.Z, v.distance
+	p.World.MoveViewer(p.viewer, p.X, p.Z, int(v.distance))
+}
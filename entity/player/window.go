@@ -0,0 +1,176 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package player
+
+import (
+	"github.com/NetherrackDev/netherrack/inventory"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"sync"
+)
+
+//The type of window shown by the client
+type WindowType byte
+
+const (
+	Chest WindowType = iota
+	Workbench
+	Furnace
+	Dispenser
+	EnchantmentTable
+	BrewingStand
+	Villager
+	Beacon
+	Anvil
+	Hopper
+	Dropper
+)
+
+//The highest window id used before they wrap around
+const maxWindowID = 100
+
+//A Container is a window that the server opens for the player. It doesn't
+//need to be backed by a block, e.g. a chest window can be used as a menu.
+type Container struct {
+	Type WindowType
+	//The title shown at the top of the window
+	Title string
+	//The items shown in the window. Chests need a multiple of 9 slots
+	//and other types need the number of slots they normally have.
+	Inventory *inventory.Inventory
+	//Called on the player's goroutine before a click is applied. Slots
+	//after the container's are the player's main inventory followed by
+	//the hotbar. Returning false cancels the click. Optional
+	OnClick func(p *Player, click protocol.WindowClick) bool
+	//Called on the player's goroutine once the window is closed by
+	//either side or the player leaves. Optional
+	OnClose func(p *Player)
+}
+
+//The window the server has opened for the player
+type openWindow struct {
+	sync.Mutex
+	//0 when no container is open
+	id        int8
+	lastID    int8
+	container *Container
+	window    *inventory.Window
+}
+
+//Opens the container for the player closing the currently open window.
+//Must be called from the player's goroutine (e.g. from its handler) or
+//whilst the player is locked with LockChan.
+func (p *Player) OpenWindow(c *Container) {
+	p.CloseWindow()
+	window := inventory.NewContainerWindow(c.Inventory, p.inventory.inv)
+
+	p.window.Lock()
+	p.window.lastID = p.window.lastID%maxWindowID + 1
+	id := p.window.lastID
+	p.window.id = id
+	p.window.container = c
+	p.window.window = window
+	p.window.Unlock()
+
+	p.QueuePacket(protocol.WindowOpen{
+		WindowID: byte(id),
+		Type:     byte(c.Type),
+		Title:    c.Title,
+		Slots:    byte(c.Inventory.Size()),
+		UseTitle: true,
+	})
+	//Sends the contents of the container
+	c.Inventory.AddWatcher(p)
+}
+
+//Closes the window the server opened for the player, if any.
+//Must be called from the player's goroutine (e.g. from its handler) or
+//whilst the player is locked with LockChan.
+func (p *Player) CloseWindow() {
+	if id := p.closeContainer(); id != 0 {
+		p.QueuePacket(protocol.WindowClose{WindowID: byte(id)})
+	}
+}
+
+//Returns the open container or nil if the player doesn't have one open
+func (p *Player) OpenContainer() *Container {
+	p.window.Lock()
+	defer p.window.Unlock()
+	return p.window.container
+}
+
+//Forgets the open container and returns the id it had, 0 if no container
+//was open
+func (p *Player) closeContainer() int8 {
+	p.window.Lock()
+	id, c := p.window.id, p.window.container
+	p.window.id = 0
+	p.window.container = nil
+	p.window.window = nil
+	p.window.Unlock()
+	if c == nil {
+		return 0
+	}
+	c.Inventory.RemoveWatcher(p)
+	p.returnCursor()
+	if c.OnClose != nil {
+		c.OnClose(p)
+	}
+	return id
+}
+
+//Returns the window with the id if it is the player's inventory or the
+//open container
+func (p *Player) windowByID(id int8) (*inventory.Window, *Container) {
+	if id == playerWindow {
+		return p.inventory.window, nil
+	}
+	p.window.Lock()
+	defer p.window.Unlock()
+	if p.window.container == nil || p.window.id != id {
+		return nil, nil
+	}
+	return p.window.window, p.window.container
+}
+
+//Sends changes to the open container to the client
+func (p *Player) containerSlotChanged(inv *inventory.Inventory, slot int, item protocol.Slot) {
+	p.window.Lock()
+	defer p.window.Unlock()
+	if p.window.container == nil || p.window.container.Inventory != inv {
+		return
+	}
+	p.QueuePacket(protocol.WindowSetSlot{
+		WindowID: byte(p.window.id),
+		Slot:     int16(p.window.window.SlotOf(inv, slot)),
+		Item:     item,
+	})
+}
+
+//Sends the full contents of the open container to the client. The
+//container's slots are first in the window so the player's inventory
+//doesn't need to be sent.
+func (p *Player) containerReset(inv *inventory.Inventory, slots []protocol.Slot) {
+	p.window.Lock()
+	defer p.window.Unlock()
+	if p.window.container == nil || p.window.container.Inventory != inv {
+		return
+	}
+	p.QueuePacket(protocol.WindowItems{
+		WindowID: byte(p.window.id),
+		Slots:    slots,
+	})
+}
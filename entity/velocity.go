@@ -0,0 +1,183 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package entity
+
+import (
+	"github.com/NetherrackDev/netherrack/blocks"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"github.com/NetherrackDev/netherrack/world"
+	"math"
+)
+
+const (
+	//The furthest an entity moves before checking for blocks again
+	velocityStep = 0.5
+	//Slower speeds are rounded down to stopped
+	minSpeed = 0.05
+	//Fraction of the horizontal velocity kept each tick whilst on the
+	//ground
+	groundFriction = 0.6
+	//The protocol's velocity is in 1/8000 of a block per client tick
+	velocityScale = 8000.0 / 20
+)
+
+//Moves entities that aren't moved by a client. Velocities are in
+//blocks per second.
+type VelocityComponent struct {
+	VX, VY, VZ float64
+	//Downwards acceleration in blocks per second per second
+	Gravity float64
+	//Fraction of the velocity lost each second to air resistance
+	Drag float64
+	//Whether the entity is resting on a block
+	OnGround bool
+
+	changed bool
+}
+
+func (v *VelocityComponent) Velocity() *VelocityComponent {
+	return v
+}
+
+//Changes the velocity and sends it to the players that can see the
+//entity. Must be called from the entity's goroutine.
+func (v *VelocityComponent) SetVelocity(x, y, z float64) {
+	v.VX, v.VY, v.VZ = x, y, z
+	v.OnGround = false
+	v.changed = true
+}
+
+//Converts a velocity in blocks per second to the protocol's format
+func PackVelocity(v float64) int16 {
+	v *= velocityScale
+	switch {
+	case v > math.MaxInt16:
+		return math.MaxInt16
+	case v < math.MinInt16:
+		return math.MinInt16
+	}
+	return int16(v)
+}
+
+func init() {
+	RegisterSystem(SystemVelocity{})
+}
+
+//Applies gravity and drag to entities and stops them at solid blocks
+type SystemVelocity struct{}
+
+type velocityEntity interface {
+	world.Entity
+	Entity() *EntityComponent
+	Position() *PositionComponent
+	Velocity() *VelocityComponent
+}
+
+func (SystemVelocity) Valid(e interface{}) bool {
+	_, ok := e.(velocityEntity)
+	return ok
+}
+
+//Runs before SystemMovable so moves are sent the same tick
+func (SystemVelocity) Priority() Priority { return High }
+
+func (SystemVelocity) Update(entity interface{}) {
+	ve := entity.(velocityEntity)
+	e := ve.Entity()
+	p := ve.Position()
+	v := ve.Velocity()
+	if v.changed {
+		v.changed = false
+		e.World.QueueEntityPacket(ve, protocol.EntityVelocity{
+			EntityID:  e.ID,
+			VelocityX: PackVelocity(v.VX),
+			VelocityY: PackVelocity(v.VY),
+			VelocityZ: PackVelocity(v.VZ),
+		})
+	}
+	//The block under the entity may have been removed
+	if v.OnGround && !solidAt(e, p.X, p.Y-velocityStep, p.Z) {
+		v.OnGround = false
+	}
+	if !v.OnGround {
+		v.VY -= v.Gravity / ticksPerSecond
+	}
+	if v.VX == 0 && v.VY == 0 && v.VZ == 0 {
+		return
+	}
+
+	dx, dy, dz := v.VX/ticksPerSecond, v.VY/ticksPerSecond, v.VZ/ticksPerSecond
+	longest := math.Max(math.Abs(dx), math.Max(math.Abs(dy), math.Abs(dz)))
+	steps := math.Ceil(longest / velocityStep)
+	dx, dy, dz = dx/steps, dy/steps, dz/steps
+	for i := 0; i < int(steps); i++ {
+		if dx != 0 {
+			if solidAt(e, p.X+dx, p.Y, p.Z) {
+				dx, v.VX = 0, 0
+			} else {
+				p.X += dx
+			}
+		}
+		if dz != 0 {
+			if solidAt(e, p.X, p.Y, p.Z+dz) {
+				dz, v.VZ = 0, 0
+			} else {
+				p.Z += dz
+			}
+		}
+		if dy != 0 {
+			if solidAt(e, p.X, p.Y+dy, p.Z) {
+				//Rest on top of the block
+				if dy < 0 {
+					p.Y = math.Floor(p.Y+dy) + 1
+					v.OnGround = true
+				}
+				dy, v.VY = 0, 0
+			} else {
+				p.Y += dy
+				v.OnGround = false
+			}
+		}
+	}
+
+	drag := 1 - v.Drag/ticksPerSecond
+	v.VX, v.VY, v.VZ = v.VX*drag, v.VY*drag, v.VZ*drag
+	if v.OnGround {
+		v.VX, v.VZ = v.VX*groundFriction, v.VZ*groundFriction
+	}
+	if math.Abs(v.VX) < minSpeed {
+		v.VX = 0
+	}
+	if math.Abs(v.VZ) < minSpeed {
+		v.VZ = 0
+	}
+	if v.OnGround && math.Abs(v.VY) < minSpeed {
+		v.VY = 0
+	}
+}
+
+//Returns whether the block at the position is solid. Blocks in chunks
+//that aren't loaded are solid so entities stop at them instead of
+//waiting for the chunk to load.
+func solidAt(e *EntityComponent, x, y, z float64) bool {
+	bx, by, bz := int(math.Floor(x)), int(math.Floor(y)), int(math.Floor(z))
+	if by < 0 || by > 255 {
+		return false
+	}
+	block, _, ok := e.World.LoadedBlock(bx, by, bz)
+	return !ok || blocks.Blocks[block].Solid
+}
@@ -17,7 +17,6 @@
 package msgpack
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/binary"
 	"fmt"
@@ -133,7 +132,11 @@ func decodeStruct(t reflect.Type) interface{} {
 					return err
 				}
 			} else {
-				err := (d.decode.(decodeReflectFunc))(dec, v.FieldByIndex(d.index))
+				f := v.FieldByIndex(d.index)
+				if f.Kind() == reflect.Interface {
+					f = f.Addr()
+				}
+				err := (d.decode.(decodeReflectFunc))(dec, f)
 				if err != nil {
 					return err
 				}
@@ -650,9 +653,7 @@ func _decodeInterface(dec *Decoder, v reflect.Value) error {
 	}
 	by := make([]byte, l)
 	dec.fr.Read(by)
-	iDec := &Decoder{
-		r: bufio.NewReader(bytes.NewReader(by)),
-	}
+	iDec := NewDecoder(bytes.NewReader(by))
 
 	id := new(string)
 	err = _decodeString(iDec, unsafe.Pointer(id))
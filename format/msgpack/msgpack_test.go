@@ -217,6 +217,63 @@ func TestMap(t *testing.T) {
 	}
 }
 
+type testInterfaceValue struct {
+	Name  string
+	Count int16
+}
+
+type testInterfaceMap struct {
+	Values map[string]interface{}
+}
+
+func TestInterfaceStruct(t *testing.T) {
+	Register(testInterfaceValue{})
+	v := testInterfaceMap{map[string]interface{}{
+		"first": testInterfaceValue{"Egg", 5},
+	}}
+
+	var buf bytes.Buffer
+	err := NewEncoder(&buf).Encode(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := testInterfaceMap{}
+	err = NewDecoder(&buf).Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, out) {
+		t.Errorf("Wanted: %v", v)
+		t.Errorf("Got: %v", out)
+	}
+}
+
+type testInterfaceField struct {
+	Value interface{}
+}
+
+func TestInterfaceField(t *testing.T) {
+	Register(testInterfaceValue{})
+	v := testInterfaceField{testInterfaceValue{"Egg", 5}}
+
+	var buf bytes.Buffer
+	err := NewEncoder(&buf).Encode(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := testInterfaceField{}
+	err = NewDecoder(&buf).Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, out) {
+		t.Errorf("Wanted: %v", v)
+		t.Errorf("Got: %v", out)
+	}
+}
+
 type testSliceBytes struct {
 	Val []byte
 }
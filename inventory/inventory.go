@@ -0,0 +1,300 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+/*
+	Inventory contains storage for items and the logic for players
+	clicking on them in windows.
+
+	Items are stored as protocol.Slots. An empty slot has an ID of -1,
+	use IsEmpty to check for empty slots as the client may also send
+	air (ID 0) or stacks with no items.
+*/
+package inventory
+
+import (
+	"bytes"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"sync"
+	"sync/atomic"
+)
+
+//An empty slot
+var Empty = protocol.Slot{ID: -1}
+
+//Returns whether the slot contains no items
+func IsEmpty(item protocol.Slot) bool {
+	return item.ID <= 0 || item.Count <= 0
+}
+
+//Returns Empty for empty slots so they are sent correctly
+func normalize(item protocol.Slot) protocol.Slot {
+	if IsEmpty(item) {
+		return Empty
+	}
+	return item
+}
+
+//Returns whether the items can be in the same stack
+func Stackable(a, b protocol.Slot) bool {
+	return a.ID == b.ID && a.Damage == b.Damage && bytes.Equal(a.Tag, b.Tag)
+}
+
+//Returns whether the slots contain the same items
+func Equal(a, b protocol.Slot) bool {
+	if IsEmpty(a) || IsEmpty(b) {
+		return IsEmpty(a) == IsEmpty(b)
+	}
+	return a.Count == b.Count && Stackable(a, b)
+}
+
+var maxStack = map[int16]int8{}
+
+func init() {
+	//Tools, weapons and armor
+	for id := int16(256); id <= 259; id++ {
+		SetMaxStack(id, 1)
+	}
+	for _, id := range []int16{261, 283, 284, 285, 286, 326, 327, 329, 333,
+		335, 342, 343, 346, 354, 355, 359, 373, 386, 387, 398, 403, 407, 408,
+		417, 418, 419} {
+		SetMaxStack(id, 1)
+	}
+	for id := int16(267); id <= 279; id++ {
+		SetMaxStack(id, 1)
+	}
+	for id := int16(290); id <= 294; id++ {
+		SetMaxStack(id, 1)
+	}
+	for id := int16(298); id <= 317; id++ {
+		SetMaxStack(id, 1)
+	}
+	//Records
+	for id := int16(2256); id <= 2267; id++ {
+		SetMaxStack(id, 1)
+	}
+	for _, id := range []int16{323, 325, 332, 344, 368} {
+		SetMaxStack(id, 16)
+	}
+}
+
+//Sets the most items of the type that can be in a single stack. Items
+//default to 64.
+//Should only be called at init.
+func SetMaxStack(id int16, size int8) {
+	maxStack[id] = size
+}
+
+//Returns the most items of the type that can be in a single stack
+func MaxStack(item protocol.Slot) int8 {
+	if size, ok := maxStack[item.ID]; ok {
+		return size
+	}
+	return 64
+}
+
+//A Watcher is told about changes to an inventory. The methods are called
+//whilst the inventory is locked so they must not use the inventory.
+type Watcher interface {
+	//Called when a single slot changes
+	SlotChanged(inv *Inventory, slot int, item protocol.Slot)
+	//Called with the full contents when the watcher is added and when
+	//every slot is replaced
+	InventoryReset(inv *Inventory, slots []protocol.Slot)
+}
+
+var lastID uint64
+
+//An Inventory is a fixed number of slots. It is safe to use from
+//multiple goroutines.
+type Inventory struct {
+	//Used to lock multiple inventories in the same order
+	id       uint64
+	lock     sync.Mutex
+	slots    []protocol.Slot
+	watchers map[Watcher]bool
+}
+
+//Creates an empty inventory with the number of slots
+func New(size int) *Inventory {
+	inv := &Inventory{
+		id:       atomic.AddUint64(&lastID, 1),
+		slots:    make([]protocol.Slot, size),
+		watchers: map[Watcher]bool{},
+	}
+	for i := range inv.slots {
+		inv.slots[i] = Empty
+	}
+	return inv
+}
+
+//Returns the number of slots in the inventory
+func (inv *Inventory) Size() int {
+	return len(inv.slots)
+}
+
+//Returns the item in the slot
+func (inv *Inventory) Slot(slot int) protocol.Slot {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	return inv.slots[slot]
+}
+
+//Changes the item in the slot
+func (inv *Inventory) SetSlot(slot int, item protocol.Slot) {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	inv.set(slot, item, nil)
+}
+
+//Returns a copy of every slot
+func (inv *Inventory) Slots() []protocol.Slot {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	return inv.copySlots()
+}
+
+//Returns a copy of every slot. The lock must be held
+func (inv *Inventory) copySlots() []protocol.Slot {
+	return append([]protocol.Slot(nil), inv.slots...)
+}
+
+//Replaces every slot with the items. Extra items are ignored and
+//missing ones are empty.
+func (inv *Inventory) SetSlots(items []protocol.Slot) {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	for i := range inv.slots {
+		if i < len(items) {
+			inv.slots[i] = normalize(items[i])
+		} else {
+			inv.slots[i] = Empty
+		}
+	}
+	for w := range inv.watchers {
+		w.InventoryReset(inv, inv.copySlots())
+	}
+}
+
+//Empties every slot
+func (inv *Inventory) Clear() {
+	inv.SetSlots(nil)
+}
+
+//Adds the item to the inventory, first on to matching stacks and then
+//into empty slots. Returns the items that didn't fit.
+func (inv *Inventory) Add(item protocol.Slot) protocol.Slot {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	slots := make([]int, len(inv.slots))
+	for i := range slots {
+		slots[i] = i
+	}
+	return inv.add(item, slots, nil)
+}
+
+//Adds the item to the slots in order, first on to matching stacks and
+//then into empty slots. Returns the items that didn't fit.
+func (inv *Inventory) AddTo(item protocol.Slot, slots []int) protocol.Slot {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	return inv.add(item, slots, nil)
+}
+
+//Adds the item to the slots in order, first on to matching stacks and
+//then into empty slots. The lock must be held
+func (inv *Inventory) add(item protocol.Slot, slots []int, origin Watcher) protocol.Slot {
+	max := MaxStack(item)
+	for pass := 0; pass < 2 && !IsEmpty(item); pass++ {
+		for _, slot := range slots {
+			current := inv.slots[slot]
+			var space int8
+			if pass == 0 && !IsEmpty(current) && Stackable(current, item) {
+				space = max - current.Count
+			} else if pass == 1 && IsEmpty(current) {
+				current = item
+				current.Count = 0
+				space = max
+			}
+			if space <= 0 {
+				continue
+			}
+			if space > item.Count {
+				space = item.Count
+			}
+			current.Count += space
+			item.Count -= space
+			inv.set(slot, current, origin)
+			if IsEmpty(item) {
+				break
+			}
+		}
+	}
+	return normalize(item)
+}
+
+//Removes up to count items from the slot and returns them
+func (inv *Inventory) Take(slot int, count int8) protocol.Slot {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	item := inv.slots[slot]
+	if IsEmpty(item) {
+		return Empty
+	}
+	taken := item
+	if taken.Count > count {
+		taken.Count = count
+	}
+	item.Count -= taken.Count
+	inv.set(slot, item, nil)
+	return normalize(taken)
+}
+
+//Adds the watcher to the inventory. The watcher is sent the current
+//contents straight away.
+func (inv *Inventory) AddWatcher(w Watcher) {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	inv.watchers[w] = true
+	w.InventoryReset(inv, inv.copySlots())
+}
+
+//Removes the watcher from the inventory
+func (inv *Inventory) RemoveWatcher(w Watcher) {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	delete(inv.watchers, w)
+}
+
+//Sends the full contents of the inventory to the watcher again
+func (inv *Inventory) Resync(w Watcher) {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	if inv.watchers[w] {
+		w.InventoryReset(inv, inv.copySlots())
+	}
+}
+
+//Changes the slot and tells every watcher apart from the origin.
+//The lock must be held
+func (inv *Inventory) set(slot int, item protocol.Slot, origin Watcher) {
+	item = normalize(item)
+	inv.slots[slot] = item
+	for w := range inv.watchers {
+		if w != origin {
+			w.SlotChanged(inv, slot, item)
+		}
+	}
+}
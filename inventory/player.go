@@ -0,0 +1,134 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inventory
+
+import (
+	"github.com/NetherrackDev/netherrack/protocol"
+)
+
+//The layout of a player's inventory
+const (
+	CraftingOutput = 0
+	CraftingGrid   = 1
+	Armor          = 5
+	Main           = 9
+	Hotbar         = 36
+	PlayerSize     = 45
+)
+
+//The pumpkin can be worn as a helmet
+const pumpkinID = 86
+
+//Creates an inventory with the player inventory's layout
+func NewPlayer() *Inventory {
+	return New(PlayerSize)
+}
+
+//Returns the slots items given to the player fill, the hotbar first
+func PlayerAddOrder() []int {
+	slots := make([]int, 0, PlayerSize-Main)
+	for i := Hotbar; i < PlayerSize; i++ {
+		slots = append(slots, i)
+	}
+	for i := Main; i < Hotbar; i++ {
+		slots = append(slots, i)
+	}
+	return slots
+}
+
+//Returns the armor slot (0 helmet to 3 boots) the item can be worn in or
+//-1 if it isn't armor
+func ArmorType(item protocol.Slot) int {
+	if item.ID == pumpkinID {
+		return 0
+	}
+	if item.ID >= 298 && item.ID <= 317 {
+		return int(item.ID-298) % 4
+	}
+	return -1
+}
+
+//Creates the window (id 0) the client uses for the player's own
+//inventory
+func NewPlayerWindow(inv *Inventory) *Window {
+	w := NewWindow()
+	w.Add(inv, 0, PlayerSize)
+	w.Hotbar = Hotbar
+	w.Accepts = func(slot int, item protocol.Slot) bool {
+		switch {
+		case slot == CraftingOutput:
+			return false
+		case slot >= Armor && slot < Main:
+			return ArmorType(item) == slot-Armor
+		}
+		return true
+	}
+	w.Limit = func(slot int) int8 {
+		if slot >= Armor && slot < Main {
+			return 1
+		}
+		return 64
+	}
+	w.ShiftTargets = func(slot int, item protocol.Slot) []int {
+		var targets []int
+		if slot >= Main {
+			if armor := ArmorType(item); armor != -1 {
+				targets = append(targets, Armor+armor)
+			}
+		}
+		switch {
+		case slot < Main:
+			targets = appendRange(targets, Main, PlayerSize)
+		case slot < Hotbar:
+			targets = appendRange(targets, Hotbar, PlayerSize)
+		default:
+			targets = appendRange(targets, Main, Hotbar)
+		}
+		return targets
+	}
+	return w
+}
+
+func appendRange(slots []int, start, end int) []int {
+	for i := start; i < end; i++ {
+		slots = append(slots, i)
+	}
+	return slots
+}
+
+//Creates a window showing the container's slots followed by the player's
+//main inventory and hotbar. This is the layout used by chests and
+//most other containers.
+func NewContainerWindow(container, player *Inventory) *Window {
+	w := NewWindow()
+	size := container.Size()
+	w.Add(container, 0, size)
+	w.Add(player, Main, PlayerSize-Main)
+	w.Hotbar = size + Hotbar - Main
+	w.ShiftTargets = func(slot int, item protocol.Slot) []int {
+		var targets []int
+		if slot < size {
+			//Into the player's inventory, hotbar first
+			for i := w.size - 1; i >= size; i-- {
+				targets = append(targets, i)
+			}
+			return targets
+		}
+		return appendRange(targets, 0, size)
+	}
+	return w
+}
@@ -0,0 +1,536 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inventory
+
+import (
+	"github.com/NetherrackDev/netherrack/protocol"
+	"sort"
+)
+
+//The slot sent for clicks outside of the window
+const outsideSlot = -999
+
+//Window click modes
+const (
+	modeClick = iota
+	modeShiftClick
+	modeNumberKey
+	modeMiddleClick
+	modeDrop
+	modePaint
+	modeDoubleClick
+)
+
+//A part of a window backed by an inventory
+type section struct {
+	inv   *Inventory
+	start int
+	count int
+}
+
+//A Window is a view of one or more inventories that a player can click
+//on. The window's slots are numbered in the order the inventories were
+//added.
+type Window struct {
+	sections []section
+	size     int
+	//The window slot of the first of the 9 hotbar slots used by the
+	//number keys, -1 if the window has no hotbar
+	Hotbar int
+	//Returns whether the item can be placed in the slot. All items are
+	//accepted if nil
+	Accepts func(slot int, item protocol.Slot) bool
+	//Returns the most items the slot can hold. The item's max stack size
+	//is used if nil
+	Limit func(slot int) int8
+	//Returns the slots a shift clicked item should be moved to in order.
+	//Shift clicking does nothing if nil
+	ShiftTargets func(slot int, item protocol.Slot) []int
+}
+
+//Creates an empty window. Inventories must be added before it is used
+func NewWindow() *Window {
+	return &Window{Hotbar: -1}
+}
+
+//Adds count slots from the inventory starting at start to the end of
+//the window
+func (w *Window) Add(inv *Inventory, start, count int) {
+	w.sections = append(w.sections, section{inv, start, count})
+	w.size += count
+}
+
+//Returns the number of slots in the window
+func (w *Window) Size() int {
+	return w.size
+}
+
+//Returns the inventory and slot in the inventory the window's slot is in
+func (w *Window) Locate(slot int) (*Inventory, int) {
+	if slot < 0 {
+		return nil, -1
+	}
+	for _, s := range w.sections {
+		if slot < s.count {
+			return s.inv, s.start + slot
+		}
+		slot -= s.count
+	}
+	return nil, -1
+}
+
+//Returns the window slot that shows the inventory's slot, -1 if the
+//slot isn't in the window
+func (w *Window) SlotOf(inv *Inventory, slot int) int {
+	offset := 0
+	for _, s := range w.sections {
+		if s.inv == inv && slot >= s.start && slot < s.start+s.count {
+			return offset + slot - s.start
+		}
+		offset += s.count
+	}
+	return -1
+}
+
+//Returns the items in every slot of the window
+func (w *Window) Slots() []protocol.Slot {
+	unlock := w.lock()
+	defer unlock()
+	slots := make([]protocol.Slot, w.size)
+	for i := range slots {
+		slots[i] = w.get(i)
+	}
+	return slots
+}
+
+//Locks every inventory in the window in a consistent order and returns
+//a function to unlock them
+func (w *Window) lock() func() {
+	var invs []*Inventory
+	for _, s := range w.sections {
+		found := false
+		for _, inv := range invs {
+			found = found || inv == s.inv
+		}
+		if !found {
+			invs = append(invs, s.inv)
+		}
+	}
+	sort.Sort(byID(invs))
+	for _, inv := range invs {
+		inv.lock.Lock()
+	}
+	return func() {
+		for _, inv := range invs {
+			inv.lock.Unlock()
+		}
+	}
+}
+
+type byID []*Inventory
+
+func (b byID) Len() int           { return len(b) }
+func (b byID) Less(i, j int) bool { return b[i].id < b[j].id }
+func (b byID) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+//The locks must be held
+func (w *Window) get(slot int) protocol.Slot {
+	inv, i := w.Locate(slot)
+	return inv.slots[i]
+}
+
+//The locks must be held
+func (w *Window) set(slot int, item protocol.Slot, origin Watcher) {
+	inv, i := w.Locate(slot)
+	inv.set(i, item, origin)
+}
+
+func (w *Window) accepts(slot int, item protocol.Slot) bool {
+	return w.Accepts == nil || w.Accepts(slot, item)
+}
+
+//Returns the most of the item that can be in the slot
+func (w *Window) limit(slot int, item protocol.Slot) int8 {
+	max := MaxStack(item)
+	if w.Limit != nil {
+		if limit := w.Limit(slot); limit < max {
+			max = limit
+		}
+	}
+	return max
+}
+
+//A Clicker is the player clicking on windows
+type Clicker struct {
+	//The item held on the cursor
+	Cursor protocol.Slot
+	//Isn't told about changes caused by its own clicks as the client
+	//makes them itself
+	Watcher Watcher
+	//Allows creative only clicks (cloning stacks)
+	Creative bool
+	//Called with items thrown out of the window. The items are destroyed
+	//if nil
+	Drop func(item protocol.Slot)
+
+	dragging   bool
+	dragButton int8
+	dragSlots  []int
+	dropped    []protocol.Slot
+}
+
+//Creates a clicker with an empty cursor
+func NewClicker(watcher Watcher) *Clicker {
+	return &Clicker{
+		Cursor:  Empty,
+		Watcher: watcher,
+	}
+}
+
+func (c *Clicker) drop(item protocol.Slot) {
+	if !IsEmpty(item) {
+		c.dropped = append(c.dropped, item)
+	}
+}
+
+func (c *Clicker) resetDrag() {
+	c.dragging = false
+	c.dragSlots = c.dragSlots[:0]
+}
+
+//Applies the click to the window. Returns false if the click was invalid
+//or the client's view of the window was out of date, in which case the
+//client should be resynced.
+func (w *Window) Click(c *Clicker, click protocol.WindowClick) bool {
+	unlock := w.lock()
+	ok := w.apply(c, click)
+	unlock()
+	//Thrown items are passed on once the window is unlocked so Drop can
+	//use the inventories
+	dropped := c.dropped
+	c.dropped = nil
+	for _, item := range dropped {
+		if c.Drop != nil {
+			c.Drop(item)
+		}
+	}
+	return ok
+}
+
+//Applies the click to the window. The locks must be held
+func (w *Window) apply(c *Clicker, click protocol.WindowClick) bool {
+	slot := int(click.Slot)
+	outside := click.Slot == outsideSlot
+	if !outside && (slot < 0 || slot >= w.size) {
+		return false
+	}
+	if click.Mode != modePaint && c.dragging {
+		c.resetDrag()
+	}
+	//The client sends the item it had in the slot before clicking
+	switch click.Mode {
+	case modeClick, modeShiftClick, modeNumberKey, modeDrop:
+		if !outside && !Equal(w.get(slot), click.Item) {
+			return false
+		}
+	}
+
+	switch click.Mode {
+	case modeClick:
+		return w.click(c, slot, outside, click.Button)
+	case modeShiftClick:
+		if outside || click.Button > 1 {
+			return false
+		}
+		w.shiftClick(c, slot)
+	case modeNumberKey:
+		if outside || click.Button < 0 || click.Button > 8 || w.Hotbar < 0 {
+			return false
+		}
+		w.swap(c, slot, w.Hotbar+int(click.Button))
+	case modeMiddleClick:
+		if outside || !c.Creative || !IsEmpty(c.Cursor) {
+			return true
+		}
+		if item := w.get(slot); !IsEmpty(item) {
+			item.Count = MaxStack(item)
+			c.Cursor = item
+		}
+	case modeDrop:
+		if outside || !IsEmpty(c.Cursor) {
+			return true
+		}
+		item := w.get(slot)
+		if IsEmpty(item) {
+			return true
+		}
+		thrown := item
+		switch click.Button {
+		case 0:
+			thrown.Count = 1
+		case 1:
+		default:
+			return false
+		}
+		item.Count -= thrown.Count
+		w.set(slot, item, c.Watcher)
+		c.drop(thrown)
+	case modePaint:
+		return w.paint(c, slot, outside, click.Button)
+	case modeDoubleClick:
+		if !outside {
+			w.collect(c)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+//Handles a left (0) or right (1) click. The locks must be held
+func (w *Window) click(c *Clicker, slot int, outside bool, button int8) bool {
+	if button != 0 && button != 1 {
+		return false
+	}
+	if outside {
+		if IsEmpty(c.Cursor) {
+			return true
+		}
+		thrown := c.Cursor
+		if button == 1 {
+			thrown.Count = 1
+		}
+		c.Cursor.Count -= thrown.Count
+		c.Cursor = normalize(c.Cursor)
+		c.drop(thrown)
+		return true
+	}
+
+	item := w.get(slot)
+	switch {
+	case IsEmpty(item) && IsEmpty(c.Cursor):
+	case IsEmpty(item):
+		if !w.accepts(slot, c.Cursor) {
+			break
+		}
+		count := c.Cursor.Count
+		if button == 1 {
+			count = 1
+		}
+		if max := w.limit(slot, c.Cursor); count > max {
+			count = max
+		}
+		item = c.Cursor
+		item.Count = count
+		c.Cursor.Count -= count
+		c.Cursor = normalize(c.Cursor)
+		w.set(slot, item, c.Watcher)
+	case IsEmpty(c.Cursor):
+		count := item.Count
+		if button == 1 {
+			count = (count + 1) / 2
+		}
+		c.Cursor = item
+		c.Cursor.Count = count
+		item.Count -= count
+		w.set(slot, item, c.Watcher)
+	case Stackable(item, c.Cursor):
+		if !w.accepts(slot, c.Cursor) {
+			//Slots that can only be taken from (e.g. crafting output) add to
+			//the cursor instead
+			if item.Count+c.Cursor.Count <= MaxStack(item) {
+				c.Cursor.Count += item.Count
+				w.set(slot, Empty, c.Watcher)
+			}
+			break
+		}
+		count := c.Cursor.Count
+		if button == 1 {
+			count = 1
+		}
+		if space := w.limit(slot, item) - item.Count; count > space {
+			count = space
+		}
+		if count <= 0 {
+			break
+		}
+		item.Count += count
+		c.Cursor.Count -= count
+		c.Cursor = normalize(c.Cursor)
+		w.set(slot, item, c.Watcher)
+	default:
+		if w.accepts(slot, c.Cursor) && c.Cursor.Count <= w.limit(slot, c.Cursor) {
+			w.set(slot, c.Cursor, c.Watcher)
+			c.Cursor = item
+		}
+	}
+	return true
+}
+
+//Moves the slot's item to the window's shift targets. The locks must be held
+func (w *Window) shiftClick(c *Clicker, slot int) {
+	item := w.get(slot)
+	if IsEmpty(item) || w.ShiftTargets == nil {
+		return
+	}
+	targets := w.ShiftTargets(slot, item)
+	for pass := 0; pass < 2 && !IsEmpty(item); pass++ {
+		for _, target := range targets {
+			if target == slot || !w.accepts(target, item) {
+				continue
+			}
+			current := w.get(target)
+			var space int8
+			if pass == 0 && !IsEmpty(current) && Stackable(current, item) {
+				space = w.limit(target, item) - current.Count
+			} else if pass == 1 && IsEmpty(current) {
+				current = item
+				current.Count = 0
+				space = w.limit(target, item)
+			}
+			if space <= 0 {
+				continue
+			}
+			if space > item.Count {
+				space = item.Count
+			}
+			current.Count += space
+			item.Count -= space
+			w.set(target, current, c.Watcher)
+			if IsEmpty(item) {
+				break
+			}
+		}
+	}
+	w.set(slot, item, c.Watcher)
+}
+
+//Swaps the items in the slot and hotbar slot. The locks must be held
+func (w *Window) swap(c *Clicker, slot, hotbar int) {
+	if slot == hotbar {
+		return
+	}
+	a, b := w.get(slot), w.get(hotbar)
+	if !IsEmpty(b) && (!w.accepts(slot, b) || b.Count > w.limit(slot, b)) {
+		return
+	}
+	if !IsEmpty(a) && (!w.accepts(hotbar, a) || a.Count > w.limit(hotbar, a)) {
+		return
+	}
+	w.set(slot, b, c.Watcher)
+	w.set(hotbar, a, c.Watcher)
+}
+
+//Handles dragging the cursor's items over slots. Buttons 0-2 start, add a
+//slot to and end a left drag, 4-6 do the same for right drags. The locks
+//must be held
+func (w *Window) paint(c *Clicker, slot int, outside bool, button int8) bool {
+	stage, kind := button&3, button>>2
+	if kind > 1 || stage > 2 {
+		c.resetDrag()
+		return false
+	}
+	switch stage {
+	case 0:
+		if !outside || IsEmpty(c.Cursor) || c.dragging {
+			c.resetDrag()
+			return false
+		}
+		c.dragging = true
+		c.dragButton = kind
+		c.dragSlots = c.dragSlots[:0]
+	case 1:
+		if !c.dragging || kind != c.dragButton || outside {
+			c.resetDrag()
+			return false
+		}
+		item := w.get(slot)
+		if !IsEmpty(item) && !Stackable(item, c.Cursor) {
+			return true
+		}
+		if !w.accepts(slot, c.Cursor) || int(c.Cursor.Count) <= len(c.dragSlots) {
+			return true
+		}
+		for _, s := range c.dragSlots {
+			if s == slot {
+				return true
+			}
+		}
+		c.dragSlots = append(c.dragSlots, slot)
+	case 2:
+		if !c.dragging || kind != c.dragButton || !outside {
+			c.resetDrag()
+			return false
+		}
+		defer c.resetDrag()
+		if len(c.dragSlots) == 0 {
+			return true
+		}
+		per := int8(1)
+		if kind == 0 {
+			per = c.Cursor.Count / int8(len(c.dragSlots))
+		}
+		for _, s := range c.dragSlots {
+			item := w.get(s)
+			if IsEmpty(item) {
+				item = c.Cursor
+				item.Count = 0
+			} else if !Stackable(item, c.Cursor) {
+				continue
+			}
+			count := per
+			if space := w.limit(s, item) - item.Count; count > space {
+				count = space
+			}
+			if count <= 0 {
+				continue
+			}
+			item.Count += count
+			c.Cursor.Count -= count
+			w.set(s, item, c.Watcher)
+		}
+		c.Cursor = normalize(c.Cursor)
+	}
+	return true
+}
+
+//Collects matching items from the window on to the cursor, taking from
+//partial stacks first. The locks must be held
+func (w *Window) collect(c *Clicker) {
+	if IsEmpty(c.Cursor) {
+		return
+	}
+	max := MaxStack(c.Cursor)
+	for pass := 0; pass < 2; pass++ {
+		for slot := 0; slot < w.size && c.Cursor.Count < max; slot++ {
+			item := w.get(slot)
+			if IsEmpty(item) || !Stackable(item, c.Cursor) || !w.accepts(slot, item) {
+				continue
+			}
+			if pass == 0 && item.Count >= max {
+				continue
+			}
+			count := item.Count
+			if space := max - c.Cursor.Count; count > space {
+				count = space
+			}
+			item.Count -= count
+			c.Cursor.Count += count
+			w.set(slot, item, c.Watcher)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inventory
+
+import (
+	"github.com/NetherrackDev/netherrack/protocol"
+	"testing"
+)
+
+func stack(id int16, count int8) protocol.Slot {
+	return protocol.Slot{ID: id, Count: count}
+}
+
+func checkSlot(t *testing.T, inv *Inventory, slot int, want protocol.Slot) {
+	if got := inv.Slot(slot); !Equal(got, want) {
+		t.Fatalf("Slot %d: got %+v, want %+v", slot, got, want)
+	}
+}
+
+func click(t *testing.T, w *Window, c *Clicker, slot int16, button, mode int8) {
+	inv, i := w.Locate(int(slot))
+	item := Empty
+	if inv != nil {
+		item = inv.Slot(i)
+	}
+	if !w.Click(c, protocol.WindowClick{Slot: slot, Button: button, Mode: mode, Item: item}) {
+		t.Fatalf("Click on %d (button %d, mode %d) rejected", slot, button, mode)
+	}
+}
+
+func TestPickupAndPlace(t *testing.T) {
+	inv := NewPlayer()
+	w := NewPlayerWindow(inv)
+	c := NewClicker(nil)
+	inv.SetSlot(Main, stack(1, 10))
+
+	//Right click takes half rounded up
+	click(t, w, c, Main, 1, modeClick)
+	checkSlot(t, inv, Main, stack(1, 5))
+	if !Equal(c.Cursor, stack(1, 5)) {
+		t.Fatalf("Unexpected cursor %+v", c.Cursor)
+	}
+	//Right click places one
+	click(t, w, c, Main+1, 1, modeClick)
+	checkSlot(t, inv, Main+1, stack(1, 1))
+	//Left click merges the rest
+	click(t, w, c, Main, 0, modeClick)
+	checkSlot(t, inv, Main, stack(1, 9))
+	if !IsEmpty(c.Cursor) {
+		t.Fatalf("Cursor not empty: %+v", c.Cursor)
+	}
+}
+
+func TestArmorSlots(t *testing.T) {
+	inv := NewPlayer()
+	w := NewPlayerWindow(inv)
+	c := NewClicker(nil)
+	c.Cursor = stack(1, 1)
+	//Stone can't be worn
+	click(t, w, c, Armor, 0, modeClick)
+	checkSlot(t, inv, Armor, Empty)
+
+	//Shift clicking a helmet equips it
+	inv.SetSlot(Hotbar, stack(310, 1))
+	click(t, w, c, Hotbar, 0, modeShiftClick)
+	checkSlot(t, inv, Armor, stack(310, 1))
+	checkSlot(t, inv, Hotbar, Empty)
+}
+
+func TestRejectOutOfSync(t *testing.T) {
+	inv := NewPlayer()
+	w := NewPlayerWindow(inv)
+	c := NewClicker(nil)
+	inv.SetSlot(Main, stack(1, 10))
+	if w.Click(c, protocol.WindowClick{Slot: Main, Mode: modeClick, Item: stack(1, 11)}) {
+		t.Fatal("Click with the wrong item accepted")
+	}
+	if w.Click(c, protocol.WindowClick{Slot: PlayerSize, Mode: modeClick, Item: Empty}) {
+		t.Fatal("Click outside of the window's slots accepted")
+	}
+	checkSlot(t, inv, Main, stack(1, 10))
+}
+
+func TestPaint(t *testing.T) {
+	inv := NewPlayer()
+	w := NewPlayerWindow(inv)
+	c := NewClicker(nil)
+	c.Cursor = stack(1, 10)
+	click(t, w, c, outsideSlot, 0, modePaint)
+	for i := int16(0); i < 3; i++ {
+		click(t, w, c, Main+i, 1, modePaint)
+	}
+	click(t, w, c, outsideSlot, 2, modePaint)
+	for i := 0; i < 3; i++ {
+		checkSlot(t, inv, Main+i, stack(1, 3))
+	}
+	if !Equal(c.Cursor, stack(1, 1)) {
+		t.Fatalf("Unexpected cursor %+v", c.Cursor)
+	}
+}
+
+func TestDropAndCollect(t *testing.T) {
+	inv := NewPlayer()
+	w := NewPlayerWindow(inv)
+	c := NewClicker(nil)
+	var dropped []protocol.Slot
+	c.Drop = func(item protocol.Slot) {
+		dropped = append(dropped, item)
+	}
+	inv.SetSlot(Main, stack(1, 64))
+	inv.SetSlot(Main+1, stack(1, 3))
+	inv.SetSlot(Main+2, stack(1, 5))
+	inv.SetSlot(Hotbar, stack(1, 10))
+
+	click(t, w, c, Main+2, 0, modeDrop)
+	checkSlot(t, inv, Main+2, stack(1, 4))
+	if len(dropped) != 1 || !Equal(dropped[0], stack(1, 1)) {
+		t.Fatalf("Unexpected drops %+v", dropped)
+	}
+
+	click(t, w, c, Hotbar, 0, modeClick)
+	click(t, w, c, Hotbar, 0, modeDoubleClick)
+	//Partial stacks are taken before full ones
+	checkSlot(t, inv, Main+1, Empty)
+	checkSlot(t, inv, Main+2, Empty)
+	checkSlot(t, inv, Main, stack(1, 17))
+	if !Equal(c.Cursor, stack(1, 64)) {
+		t.Fatalf("Unexpected cursor %+v", c.Cursor)
+	}
+}
+
+func TestContainerWindow(t *testing.T) {
+	chest := New(27)
+	player := NewPlayer()
+	w := NewContainerWindow(chest, player)
+	c := NewClicker(nil)
+	if w.Size() != 27+36 {
+		t.Fatalf("Unexpected window size %d", w.Size())
+	}
+	if slot := w.SlotOf(player, Hotbar); slot != 27+27 {
+		t.Fatalf("Hotbar in slot %d", slot)
+	}
+
+	//Shift clicking moves items from the chest to the hotbar first
+	chest.SetSlot(0, stack(1, 5))
+	click(t, w, c, 0, 0, modeShiftClick)
+	checkSlot(t, chest, 0, Empty)
+	checkSlot(t, player, PlayerSize-1, stack(1, 5))
+
+	//Number keys swap with the player's hotbar
+	click(t, w, c, 3, 8, modeNumberKey)
+	checkSlot(t, chest, 3, stack(1, 5))
+	checkSlot(t, player, PlayerSize-1, Empty)
+}
@@ -25,17 +25,24 @@ package netherrack
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/NetherrackDev/netherrack/entity/player"
 	"github.com/NetherrackDev/netherrack/message"
 	"github.com/NetherrackDev/netherrack/protocol"
 	"github.com/NetherrackDev/netherrack/protocol/auth"
+	"github.com/NetherrackDev/netherrack/protocol/capture"
+	"github.com/NetherrackDev/netherrack/scoreboard"
 	"github.com/NetherrackDev/netherrack/world"
 	"log"
 	"net"
+	"os"
+	"path/filepath"
 	"runtime"
 	"runtime/debug"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 const (
@@ -43,6 +50,11 @@ const (
 	ProtocolVersion = protocol.Version
 	//The currently supported Minecraft version
 	MinecraftVersion = "1.7.2"
+	//Radius in chunks players can see unless changed with
+	//SetViewDistance
+	defaultViewDistance = 10
+	//Sent to a player when someone else logs in with their uuid
+	loggedInElsewhere = "You logged in from another location"
 )
 
 var (
@@ -68,13 +80,18 @@ type Server struct {
 	}
 
 	authenticator protocol.Authenticator
+	captureDir    string
+	viewDistance  int
+	scoreboard    *scoreboard.Scoreboard
 
 	Handler ServerHandler
 
 	global struct {
-		packet chan protocol.Packet
-		add    chan *player.Player
-		remove chan *player.Player
+		packet        chan protocol.Packet
+		pluginMessage chan protocol.PluginMessage
+		add           chan globalJoin
+		remove        chan *player.Player
+		tabList       chan func(t *tabList)
 	}
 
 	ping struct {
@@ -90,13 +107,17 @@ type Server struct {
 func NewServer() *Server {
 	server := &Server{
 		authenticator: auth.Instance,
+		scoreboard:    scoreboard.New(),
+		viewDistance:  defaultViewDistance,
 	}
 	server.worlds.m = make(map[string]*world.World)
 	server.worlds.waitMap = make(map[string]*sync.WaitGroup)
 	server.worlds.tryClose = make(chan world.TryClose, 2)
 	server.global.packet = make(chan protocol.Packet, 200)
-	server.global.add = make(chan *player.Player, 20)
+	server.global.pluginMessage = make(chan protocol.PluginMessage, 50)
+	server.global.add = make(chan globalJoin, 20)
 	server.global.remove = make(chan *player.Player, 20)
+	server.global.tabList = make(chan func(t *tabList), 50)
 	return server
 }
 
@@ -113,6 +134,15 @@ func (server *Server) Start(address string) error {
 	if err != nil {
 		return err
 	}
+	return server.Serve(listen)
+}
+
+//Serve accepts connections from the passed listener. This will block while
+//the server is running until the listener is closed. Start should be used
+//instead in most cases but this is useful for when the address isn't known
+//beforehand (e.g. tests on a random port).
+func (server *Server) Serve(listen net.Listener) error {
+	server.running = true
 	server.listener = listen
 
 	go server.globalServer()
@@ -147,19 +177,56 @@ func (server *Server) worldServer() {
 	}
 }
 
+//A player joining the server. ok is sent whether the player joined,
+//a later login with the same uuid can take its place whilst waiting.
+type globalJoin struct {
+	p  *player.Player
+	ok chan bool
+}
+
 //Handles sending packets to all players on the server
 func (server *Server) globalServer() {
 	players := map[string]*player.Player{}
+	//Players waiting for the session with their uuid to leave
+	waiting := map[string]globalJoin{}
+	tab := newTabList()
 	for {
 		select {
 		case packet := <-server.global.packet:
 			for _, p := range players {
 				p.QueuePacket(packet)
 			}
-		case p := <-server.global.add:
-			players[p.UUID()] = p
+		case pm := <-server.global.pluginMessage:
+			for _, p := range players {
+				p.SendPluginMessage(pm.Channel, pm.Data)
+			}
+		case j := <-server.global.add:
+			uuid := j.p.UUID()
+			if old, ok := players[uuid]; ok {
+				//Like vanilla the existing session is kicked. The new
+				//one waits for it to leave so it loads the saved data.
+				old.Kick(loggedInElsewhere)
+				if w, ok := waiting[uuid]; ok {
+					w.ok <- false
+				}
+				waiting[uuid] = j
+				continue
+			}
+			players[uuid] = j.p
+			tab.add(j.p)
+			j.ok <- true
 		case p := <-server.global.remove:
-			players[p.UUID()] = p
+			uuid := p.UUID()
+			delete(players, uuid)
+			tab.remove(p)
+			if j, ok := waiting[uuid]; ok {
+				delete(waiting, uuid)
+				players[uuid] = j.p
+				tab.add(j.p)
+				j.ok <- true
+			}
+		case f := <-server.global.tabList:
+			f(tab)
 		}
 	}
 }
@@ -179,6 +246,17 @@ func (server *Server) SetAuthenticator(auth protocol.Authenticator) {
 	server.authenticator = auth
 }
 
+//SetCaptureDirectory enables recording every player's packets to a
+//capture file in dir named after the player and the time they joined.
+//The captures can be read with the capture package. This panics if the
+//server is started.
+func (server *Server) SetCaptureDirectory(dir string) {
+	if server.running {
+		panic("Server is running")
+	}
+	server.captureDir = dir
+}
+
 //SetDefaultWorld sets the default world for the server. This panics
 //if the server is started.
 func (server *Server) SetDefaultWorld(def string) {
@@ -188,6 +266,22 @@ func (server *Server) SetDefaultWorld(def string) {
 	server.worlds.def = def
 }
 
+//SetViewDistance changes the radius in chunks players can see in
+//worlds that don't set their own. Players that ask for less see less.
+//This panics if the server is started.
+func (server *Server) SetViewDistance(distance int) {
+	if server.running {
+		panic("Server is running")
+	}
+	server.viewDistance = distance
+}
+
+//ViewDistance returns the radius in chunks players can see in worlds
+//that don't set their own
+func (server *Server) ViewDistance() int {
+	return server.viewDistance
+}
+
 //DefaultWorld returns the default world for the server
 func (server *Server) DefaultWorld() *world.World {
 	return server.World(server.worlds.def)
@@ -343,37 +437,86 @@ func (server *Server) handleConnection(conn net.Conn) {
 		return
 	}
 
+	if server.captureDir != "" {
+		if rec, err := server.startCapture(username); err != nil {
+			log.Printf("Player %s(%s) capture error: %s", uuid, username, err)
+		} else {
+			rec.Wrap(mcConn)
+			defer rec.Close()
+		}
+	}
+
 	p := player.NewPlayer(uuid, username, mcConn, server)
 
-	//Adds the player to server
-	server.global.add <- p
+	ok, msg := server.Handler.PlayerJoin(p)
+	if ok {
+		p.Reject(msg)
+		return
+	}
+
+	//Adds the player to server, kicking any other session with the
+	//same uuid
+	joined := make(chan bool, 1)
+	server.global.add <- globalJoin{p, joined}
+	if !<-joined {
+		p.Reject(loggedInElsewhere)
+		return
+	}
 	atomic.AddInt32(&server.playerCount, 1)
 	defer func() {
 		server.global.remove <- p
 		atomic.AddInt32(&server.playerCount, -1)
 	}()
 
-	ok, msg := server.Handler.PlayerJoin(p)
-	if ok {
-		mcConn.WritePacket(protocol.Disconnect{msg})
-		server.global.remove <- p
-		return
-	}
-
 	//This will block until the player logouts or is kicked
 	p.Start()
 }
 
+func (server *Server) startCapture(username string) (*capture.Recorder, error) {
+	if err := os.MkdirAll(server.captureDir, 0777); err != nil {
+		return nil, err
+	}
+	//The username comes from the client so only characters that are
+	//safe in a file name are kept
+	safe := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return -1
+	}, username)
+	name := fmt.Sprintf("%s-%d.nrcap", safe, time.Now().UnixNano())
+	f, err := os.Create(filepath.Join(server.captureDir, name))
+	if err != nil {
+		return nil, err
+	}
+	return capture.NewRecorder(f), nil
+}
+
 //QueuePacket queues the packet to be send to every player on the server
 func (server *Server) QueuePacket(packet protocol.Packet) {
 	server.global.packet <- packet
 }
 
+//SendPluginMessage sends the plugin message to every player on the server
+//that has registered the channel
+func (server *Server) SendPluginMessage(channel string, data []byte) {
+	server.global.pluginMessage <- protocol.PluginMessage{
+		Channel: channel,
+		Data:    data,
+	}
+}
+
 //SendMessage sends the message to every player on the server
 func (server *Server) SendMessage(msg *message.Message) {
 	server.QueuePacket(protocol.ServerMessage{msg.JSONString()})
 }
 
+//Scoreboard returns the scoreboard shown to every player that hasn't
+//been given their own
+func (server *Server) Scoreboard() *scoreboard.Scoreboard {
+	return server.scoreboard
+}
+
 //SetPing set the ping to be showed on minecraft's server browser
 func (server *Server) SetPing(ping Ping) {
 	server.ping.Lock()
@@ -0,0 +1,243 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+/*
+	Package capture records the packets sent over a protocol.Conn to a
+	file and reads them back. Captures can be dumped as json to see what
+	a server sent a client or replayed against a server to reproduce bugs.
+
+	Packets are stored as the raw (decrypted) frames so that the capture
+	is still readable if a packet fails to decode.
+*/
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/NetherrackDev/netherrack/format/msgpack"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	magic   = "NRCAP"
+	version = 1
+)
+
+var ErrorInvalidCapture = errors.New("Invalid capture file")
+
+type header struct {
+	Magic   string
+	Version int32
+	Start   int64
+}
+
+//A single packet in a capture
+type Record struct {
+	//Nanoseconds since the capture started
+	Time int64
+	//The direction the packet was travelling in
+	Direction int8
+	//The protocol state the connection was in when the packet was sent
+	State int8
+	//The packet's frame, including the length prefix
+	Data []byte
+}
+
+//Decodes the record's packet
+func (r *Record) Packet() (protocol.Packet, error) {
+	conn := &protocol.Conn{
+		In:            bytes.NewReader(r.Data),
+		State:         protocol.State(r.State),
+		ReadDirection: protocol.Direction(r.Direction),
+	}
+	return conn.ReadPacket()
+}
+
+//A Recorder writes the packets sent and received by connections to
+//a capture.
+type Recorder struct {
+	lock   sync.Mutex
+	w      *bufio.Writer
+	closer io.Closer
+	enc    *msgpack.Encoder
+	start  time.Time
+	closed bool
+}
+
+//Creates a recorder that writes a capture to w. If w is an io.Closer
+//it will be closed when the recorder is closed.
+func NewRecorder(w io.Writer) *Recorder {
+	bw := bufio.NewWriter(w)
+	rec := &Recorder{
+		w:     bw,
+		enc:   msgpack.NewEncoder(bw),
+		start: time.Now(),
+	}
+	rec.closer, _ = w.(io.Closer)
+	rec.enc.Encode(&header{
+		Magic:   magic,
+		Version: version,
+		Start:   rec.start.UnixNano(),
+	})
+	bw.Flush()
+	return rec
+}
+
+//Wraps the connection's In and Out so that every packet that passes
+//through them is recorded. This should be called after the connection
+//has enabled encryption (if it will) so the recorded packets are readable.
+func (rec *Recorder) Wrap(conn *protocol.Conn) {
+	conn.In = &recordReader{
+		r:  conn.In,
+		fs: frameSplitter{rec: rec, conn: conn, direction: conn.ReadDirection},
+	}
+	conn.Out = &recordWriter{
+		w:  conn.Out,
+		fs: frameSplitter{rec: rec, conn: conn, direction: conn.WriteDirection},
+	}
+}
+
+func (rec *Recorder) record(direction protocol.Direction, state protocol.State, frame []byte) {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+	if rec.closed {
+		return
+	}
+	data := make([]byte, len(frame))
+	copy(data, frame)
+	rec.enc.Encode(&Record{
+		Time:      int64(time.Since(rec.start)),
+		Direction: int8(direction),
+		State:     int8(state),
+		Data:      data,
+	})
+	//Flushed every packet so the capture is still useful if
+	//the server crashes
+	rec.w.Flush()
+}
+
+//Flushes and stops recording. Packets recorded after this are ignored.
+func (rec *Recorder) Close() error {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+	if rec.closed {
+		return nil
+	}
+	rec.closed = true
+	err := rec.w.Flush()
+	if rec.closer != nil {
+		if cerr := rec.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+//Splits a stream of bytes back into the packet frames
+type frameSplitter struct {
+	rec       *Recorder
+	conn      *protocol.Conn
+	direction protocol.Direction
+	buf       []byte
+}
+
+func (fs *frameSplitter) feed(b []byte) {
+	fs.buf = append(fs.buf, b...)
+	offset := 0
+	for {
+		l, n := binary.Uvarint(fs.buf[offset:])
+		if n <= 0 {
+			break
+		}
+		end := offset + n + int(l)
+		if end > len(fs.buf) {
+			break
+		}
+		fs.rec.record(fs.direction, fs.conn.State, fs.buf[offset:end])
+		offset = end
+	}
+	if offset > 0 {
+		fs.buf = fs.buf[:copy(fs.buf, fs.buf[offset:])]
+	}
+}
+
+type recordReader struct {
+	r  io.Reader
+	fs frameSplitter
+}
+
+func (rr *recordReader) Read(b []byte) (int, error) {
+	n, err := rr.r.Read(b)
+	if n > 0 {
+		rr.fs.feed(b[:n])
+	}
+	return n, err
+}
+
+type recordWriter struct {
+	w  io.Writer
+	fs frameSplitter
+}
+
+func (rw *recordWriter) Write(b []byte) (int, error) {
+	n, err := rw.w.Write(b)
+	if n > 0 {
+		rw.fs.feed(b[:n])
+	}
+	return n, err
+}
+
+//Reads records from a capture
+type Reader struct {
+	dec *msgpack.Decoder
+	//The time the capture was started
+	Start time.Time
+}
+
+//Creates a reader for the capture in r
+func NewReader(r io.Reader) (*Reader, error) {
+	dec := msgpack.NewDecoder(r)
+	h := header{}
+	if err := dec.Decode(&h); err != nil || h.Magic != magic {
+		return nil, ErrorInvalidCapture
+	}
+	if h.Version != version {
+		return nil, ErrorInvalidCapture
+	}
+	return &Reader{
+		dec:   dec,
+		Start: time.Unix(0, h.Start),
+	}, nil
+}
+
+//Returns the next record in the capture. io.EOF is returned at the
+//end of the capture.
+func (r *Reader) Next() (*Record, error) {
+	rec := &Record{}
+	if err := r.dec.Decode(rec); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			//A capture cut off whilst writing the last record
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	return rec, nil
+}
@@ -0,0 +1,119 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package capture
+
+import (
+	"bytes"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndRead(t *testing.T) {
+	sent := []protocol.Packet{
+		protocol.KeepAlive{KeepAliveID: 55},
+		protocol.ServerMessage{Message: `{"text":"hello"}`},
+		protocol.PlayerPositionLook{X: 1, Y: 70, Z: -3, Yaw: 90, OnGround: true},
+	}
+	received := []protocol.Packet{
+		protocol.ClientKeepAlive{KeepAliveID: 55},
+		protocol.ChatMessage{Message: "hi"},
+	}
+
+	//The packets the 'client' sends to the server
+	var in bytes.Buffer
+	clientConn := &protocol.Conn{Out: &in, State: protocol.Play, WriteDirection: protocol.Serverbound}
+	for _, p := range received {
+		clientConn.WritePacket(p)
+	}
+
+	var capBuf, out bytes.Buffer
+	conn := &protocol.Conn{
+		In:             &in,
+		Out:            &out,
+		State:          protocol.Play,
+		ReadDirection:  protocol.Serverbound,
+		WriteDirection: protocol.Clientbound,
+	}
+	rec := NewRecorder(&capBuf)
+	rec.Wrap(conn)
+	for _, p := range sent {
+		conn.WritePacket(p)
+	}
+	for range received {
+		if _, err := conn.ReadPacket(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec.Close()
+
+	r, err := NewReader(&capBuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotSent, gotReceived []protocol.Packet
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		packet, err := record.Packet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if protocol.Direction(record.Direction) == protocol.Clientbound {
+			gotSent = append(gotSent, packet)
+		} else {
+			gotReceived = append(gotReceived, packet)
+		}
+	}
+	if !reflect.DeepEqual(sent, gotSent) {
+		t.Errorf("Sent packets mismatch: %v != %v", sent, gotSent)
+	}
+	if !reflect.DeepEqual(received, gotReceived) {
+		t.Errorf("Received packets mismatch: %v != %v", received, gotReceived)
+	}
+}
+
+func TestDumpJSON(t *testing.T) {
+	var capBuf, out bytes.Buffer
+	conn := &protocol.Conn{Out: &out, State: protocol.Play, WriteDirection: protocol.Clientbound}
+	rec := NewRecorder(&capBuf)
+	rec.Wrap(conn)
+	conn.WritePacket(protocol.TimeUpdate{AgeOfTheWorld: 5, TimeOfDay: 6})
+	rec.Close()
+
+	r, err := NewReader(&capBuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dump bytes.Buffer
+	if err := DumpJSON(&dump, r); err != nil {
+		t.Fatal(err)
+	}
+	line := dump.String()
+	for _, want := range []string{`"direction":"clientbound"`, `"type":"TimeUpdate"`, `"TimeOfDay":6`} {
+		if !strings.Contains(line, want) {
+			t.Errorf("Missing %s in %s", want, line)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package capture
+
+import (
+	"encoding/json"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
+
+//The json form of a record used by DumpJSON
+type jsonRecord struct {
+	Time      string          `json:"time"`
+	Direction string          `json:"direction"`
+	State     string          `json:"state"`
+	Type      string          `json:"type,omitempty"`
+	Packet    protocol.Packet `json:"packet,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+//Writes every record in the capture to w as json, one record per line.
+//Packets that fail to decode are included with the error instead.
+func DumpJSON(w io.Writer, r *Reader) error {
+	enc := json.NewEncoder(w)
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		jr := jsonRecord{
+			Time:      time.Duration(rec.Time).String(),
+			Direction: protocol.Direction(rec.Direction).String(),
+			State:     protocol.State(rec.State).String(),
+		}
+		packet, err := rec.Packet()
+		if err != nil {
+			jr.Error = err.Error()
+		} else {
+			jr.Type = reflect.TypeOf(packet).Name()
+			jr.Packet = packet
+		}
+		if err := enc.Encode(&jr); err != nil {
+			return err
+		}
+	}
+}
+
+//Replays the serverbound play packets in the capture over conn keeping
+//the original timing (scaled by speed, 2 is twice as fast). The
+//connection must have already logged in as a client. Keep alives in the
+//capture are skipped and the server's keep alives are answered instead
+//so the server doesn't disconnect the replay.
+func Replay(conn *protocol.Conn, r *Reader, speed float64) error {
+	if speed <= 0 {
+		speed = 1
+	}
+	var writeLock sync.Mutex
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			packet, err := conn.ReadPacket()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			if ka, ok := packet.(protocol.KeepAlive); ok {
+				writeLock.Lock()
+				conn.WritePacket(protocol.ClientKeepAlive{KeepAliveID: ka.KeepAliveID})
+				writeLock.Unlock()
+			}
+		}
+	}()
+
+	var start time.Time
+	var base int64 = -1
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if protocol.Direction(rec.Direction) != protocol.Serverbound ||
+			protocol.State(rec.State) != protocol.Play {
+			continue
+		}
+		packet, err := rec.Packet()
+		if err != nil {
+			return err
+		}
+		if _, ok := packet.(protocol.ClientKeepAlive); ok {
+			continue
+		}
+		if base == -1 {
+			base = rec.Time
+			start = time.Now()
+		}
+		at := start.Add(time.Duration(float64(rec.Time-base) / speed))
+		select {
+		case <-time.After(at.Sub(time.Now())):
+		case err := <-readErr:
+			return err
+		}
+		writeLock.Lock()
+		err = conn.WritePacket(packet)
+		writeLock.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -136,8 +136,9 @@ func (conn *Conn) ReadPacket() (Packet, error) {
 	return val.Interface().(Packet), nil
 }
 
-//Writes the packet to conn
-func (conn *Conn) WritePacket(packet Packet) {
+//Writes the packet to conn. If Out is buffered the packet may not be
+//sent until Flush is called. The returned error is from writing to Out.
+func (conn *Conn) WritePacket(packet Packet) error {
 	if conn.Deadliner != nil {
 		conn.Deadliner.SetWriteDeadline(time.Now().Add(10 * time.Second))
 	}
@@ -164,9 +165,7 @@ func (conn *Conn) WritePacket(packet Packet) {
 		binary.Write(conn.Out, binary.BigEndian, p.Data)
 		binary.Write(conn.Out, binary.BigEndian, p.Meta)
 		conn.Out = temp
-		writeVarInt(conn, VarInt(buf.Len()))
-		buf.WriteTo(conn.Out)
-		return
+		return conn.writeFrame(&buf)
 	}
 
 	fs := fields(ty)
@@ -177,8 +176,34 @@ func (conn *Conn) WritePacket(packet Packet) {
 		}
 	}
 	conn.Out = temp
-	writeVarInt(conn, VarInt(buf.Len()))
-	buf.WriteTo(conn.Out)
+	return conn.writeFrame(&buf)
+}
+
+//Writes the length prefixed packet data to Out
+func (conn *Conn) writeFrame(buf *bytes.Buffer) error {
+	bs := conn.b[:]
+	n := binary.PutUvarint(bs, uint64(uint32(buf.Len())))
+	if _, err := conn.Out.Write(bs[:n]); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(conn.Out)
+	return err
+}
+
+type flusher interface {
+	Flush() error
+}
+
+//Flushes Out if it is buffered (e.g. a bufio.Writer)
+func (conn *Conn) Flush() error {
+	f, ok := conn.Out.(flusher)
+	if !ok {
+		return nil
+	}
+	if conn.Deadliner != nil {
+		conn.Deadliner.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	}
+	return f.Flush()
 }
 
 var fieldCache struct {
@@ -242,6 +267,15 @@ type field struct {
 	read      decoder
 }
 
+//Returns the value of a signed or unsigned integer field
+func intValue(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint())
+	}
+	return v.Int()
+}
+
 //Returns the field or fields needed to fully write the struct's field
 func compileField(sf reflect.StructField, t reflect.Type, ind []int) []field {
 	temp := sf.Index[0]
@@ -276,17 +310,17 @@ func compileField(sf reflect.StructField, t reflect.Type, ind []int) []field {
 		switch args[1] {
 		case "!=":
 			f.condition = func(root reflect.Value) bool {
-				val := root.FieldByIndex(in).Int()
+				val := intValue(root.FieldByIndex(in))
 				for _, v := range vals {
-					if v != val {
-						return true
+					if v == val {
+						return false
 					}
 				}
-				return false
+				return true
 			}
 		case "==":
 			f.condition = func(root reflect.Value) bool {
-				val := root.FieldByIndex(ind).Int()
+				val := intValue(root.FieldByIndex(in))
 				for _, v := range vals {
 					if v == val {
 						return true
@@ -562,6 +596,15 @@ func getSliceCoders(e reflect.Type, sf reflect.StructField) (encoder, decoder) {
 type encoder func(conn *Conn, field reflect.Value)
 type decoder func(conn *Conn, field reflect.Value) error
 
+//Returns whether the value's type can be sent as entity metadata
+func IsMetadataType(v interface{}) bool {
+	switch v.(type) {
+	case int8, int16, int32, float32, string, Slot, MetadataPosition:
+		return true
+	}
+	return false
+}
+
 func encodeMetadata(conn *Conn, field reflect.Value) {
 	m := field.Interface().(map[byte]interface{})
 	index := []byte{0}
@@ -606,6 +649,16 @@ func encodeMetadata(conn *Conn, field reflect.Value) {
 					f.write(conn, v)
 				}
 			}
+		case MetadataPosition:
+			manual = true
+			ty = 6
+			index[0] = (i & 0x1F) | (ty << 5)
+			conn.Out.Write(index)
+			binary.Write(conn.Out, binary.BigEndian, v)
+		default:
+			//Unsupported values are left out instead of taking down
+			//the writer, they are rejected by entity metadata anyway
+			continue
 		}
 		if !manual {
 			index[0] = (i & 0x1F) | (ty << 5)
@@ -686,6 +739,14 @@ func decodeMetadata(conn *Conn, field reflect.Value) error {
 				}
 			}
 			v = val.Interface()
+		case 6:
+			var pos MetadataPosition
+			if err := binary.Read(conn.In, binary.BigEndian, &pos); err != nil {
+				return err
+			}
+			v = pos
+		default:
+			return fmt.Errorf("Invalid metadata type %d", ty)
 		}
 		m[i] = v
 		_, err := io.ReadFull(conn.In, index)
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/md5"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -126,6 +127,7 @@ func (conn *Conn) Login(handshake Handshake, authenticator Authenticator) (usern
 		return
 	}
 	if !bytes.Equal(verifyToken, verifyTokenResponse) {
+		err = ErrorVerifyFailed
 		return
 	}
 
@@ -134,9 +136,7 @@ func (conn *Conn) Login(handshake Handshake, authenticator Authenticator) (usern
 			return
 		}
 	} else {
-		idBytes := make([]byte, 16)
-		rand.Read(idBytes)
-		uuid = hex.EncodeToString(idBytes)
+		uuid = OfflineUUID(username)
 	}
 
 	aesCipher, err := aes.NewCipher(sharedSecret)
@@ -157,3 +157,111 @@ func (conn *Conn) Login(handshake Handshake, authenticator Authenticator) (usern
 
 	return
 }
+
+//Returns the uuid used for the username when the server isn't
+//authenticating players. This matches the one vanilla servers use
+//so players keep the same uuid between logins.
+func OfflineUUID(username string) string {
+	id := md5.Sum([]byte("OfflinePlayer:" + username))
+	//Version 3 (name based) uuid
+	id[6] = id[6]&0x0f | 0x30
+	id[8] = id[8]&0x3f | 0x80
+	return hex.EncodeToString(id[:])
+}
+
+//Logs into a server as a client and returns the uuid the server assigned.
+//The handshake is sent by this method so the connection must be fresh.
+//Only offline mode servers are supported as the client doesn't contact
+//the Mojang session servers.
+func (conn *Conn) ClientLogin(address string, port uint16, username string) (uuid string, err error) {
+	err = conn.WritePacket(Handshake{
+		ProtocolVersion: Version,
+		Address:         address,
+		Port:            port,
+		State:           2,
+	})
+	if err != nil {
+		return
+	}
+	conn.State = Login
+
+	if err = conn.WritePacket(LoginStart{username}); err != nil {
+		return
+	}
+
+	packet, err := conn.ReadPacket()
+	if err != nil {
+		return
+	}
+	if disconnect, ok := packet.(LoginDisconnect); ok {
+		err = fmt.Errorf("Disconnected: %s", disconnect.Data)
+		return
+	}
+	encryptionRequest, ok := packet.(EncryptionKeyRequest)
+	if !ok {
+		err = fmt.Errorf("Unexpected packet")
+		return
+	}
+
+	key, err := x509.ParsePKIXPublicKey(encryptionRequest.PublicKey)
+	if err != nil {
+		return
+	}
+	publicKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		err = ErrorEncryption
+		return
+	}
+
+	sharedSecret := make([]byte, 16)
+	rand.Read(sharedSecret)
+
+	encryptedSecret, err := rsa.EncryptPKCS1v15(rand.Reader, publicKey, sharedSecret)
+	if err != nil {
+		return
+	}
+	encryptedToken, err := rsa.EncryptPKCS1v15(rand.Reader, publicKey, encryptionRequest.VerifyToken)
+	if err != nil {
+		return
+	}
+
+	err = conn.WritePacket(EncryptionKeyResponse{
+		SharedSecret: encryptedSecret,
+		VerifyToken:  encryptedToken,
+	})
+	if err != nil {
+		return
+	}
+
+	aesCipher, err := aes.NewCipher(sharedSecret)
+	if err != nil {
+		return
+	}
+
+	conn.In = cipher.StreamReader{
+		R: conn.In,
+		S: newCFB8Decrypt(aesCipher, sharedSecret),
+	}
+	conn.Out = cipher.StreamWriter{
+		W: conn.Out,
+		S: newCFB8Encrypt(aesCipher, sharedSecret),
+	}
+
+	packet, err = conn.ReadPacket()
+	if err != nil {
+		return
+	}
+	if disconnect, ok := packet.(LoginDisconnect); ok {
+		err = fmt.Errorf("Disconnected: %s", disconnect.Data)
+		return
+	}
+	success, ok := packet.(LoginSuccess)
+	if !ok {
+		err = fmt.Errorf("Unexpected packet")
+		return
+	}
+	uuid = success.UUID
+	conn.State = Play
+
+	return
+}
@@ -231,6 +231,11 @@ type EntityMetadata struct {
 	Metadata map[byte]interface{} `metadata:"true"`
 }
 
+//A position stored in entity metadata
+type MetadataPosition struct {
+	X, Y, Z int32
+}
+
 type EntityEffect struct {
 	EntityID  int32
 	EffectID  int8
@@ -482,10 +487,10 @@ type ScoreboardObjective struct {
 }
 
 type UpdateScore struct {
-	ObjectiveName string
+	ItemName      string
 	Mode          int8
-	Name          string
-	Value         int32
+	ObjectiveName string `if:"Mode,!=,1"`
+	Value         int32  `if:"Mode,!=,1"`
 }
 
 type DisplayScoreboard struct {
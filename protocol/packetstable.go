@@ -29,6 +29,20 @@ const (
 	Status
 )
 
+func (s State) String() string {
+	switch s {
+	case Handshaking:
+		return "handshaking"
+	case Play:
+		return "play"
+	case Login:
+		return "login"
+	case Status:
+		return "status"
+	}
+	return "unknown"
+}
+
 type Direction int
 
 const (
@@ -36,6 +50,16 @@ const (
 	Clientbound
 )
 
+func (d Direction) String() string {
+	switch d {
+	case Serverbound:
+		return "serverbound"
+	case Clientbound:
+		return "clientbound"
+	}
+	return "unknown"
+}
+
 var (
 	packets = [4][2][]reflect.Type{
 		Handshaking: [2][]reflect.Type{
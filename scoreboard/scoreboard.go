@@ -0,0 +1,414 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+/*
+	Scoreboard keeps track of objectives, scores and teams and keeps
+	the clients viewing a scoreboard in sync with it. Only the changes
+	made are sent to viewers and new viewers are sent the full state.
+
+	A scoreboard can be shared by every player on a server or be used
+	by a single player.
+*/
+package scoreboard
+
+import (
+	"errors"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"sync"
+)
+
+const (
+	//The longest objective, team or item name the client supports
+	maxNameLength = 16
+	//The longest display name the client supports
+	maxDisplayNameLength = 32
+)
+
+var (
+	ErrorNameTooLong = errors.New("scoreboard: Name too long")
+	ErrorExists      = errors.New("scoreboard: Name already in use")
+)
+
+//A Slot is a place on the client's screen an objective can be displayed in
+type Slot int8
+
+const (
+	//The player list (shown whilst holding tab)
+	List Slot = iota
+	//The side of the screen
+	Sidebar
+	//Under the player's name tag
+	BelowName
+	numSlots
+)
+
+//A Viewer is sent the changes to a scoreboard
+type Viewer interface {
+	QueuePacket(packet protocol.Packet)
+}
+
+//A Scoreboard holds objectives and teams. It is safe to use from
+//multiple goroutines.
+type Scoreboard struct {
+	lock sync.Mutex
+	//Packets waiting for the lock to be released before being sent
+	pending []queuedPacket
+	//Guards outbox and sending. Only one goroutine sends at a time so
+	//packets arrive in the order the changes were made.
+	sendLock sync.Mutex
+	outbox   []queuedPacket
+	sending  bool
+
+	objectives map[string]*Objective
+	display    [numSlots]*Objective
+	teams      map[string]*Team
+	//The team each item is on
+	itemTeams map[string]*Team
+	viewers   map[Viewer]bool
+}
+
+//Creates an empty scoreboard
+func New() *Scoreboard {
+	return &Scoreboard{
+		objectives: map[string]*Objective{},
+		teams:      map[string]*Team{},
+		itemTeams:  map[string]*Team{},
+		viewers:    map[Viewer]bool{},
+	}
+}
+
+type queuedPacket struct {
+	v      Viewer
+	packet protocol.Packet
+}
+
+//Queues the packet to be sent to the viewer once the lock is released.
+//The lock must be held
+func (sb *Scoreboard) send(v Viewer, packet protocol.Packet) {
+	sb.pending = append(sb.pending, queuedPacket{v, packet})
+}
+
+//Queues the packet to be sent to every viewer once the lock is
+//released. The lock must be held
+func (sb *Scoreboard) broadcast(packet protocol.Packet) {
+	for v := range sb.viewers {
+		sb.send(v, packet)
+	}
+}
+
+//Releases the lock and then sends the queued packets. Viewers aren't
+//called whilst either lock is held so they can't block the scoreboard
+//and can change it from QueuePacket. If another goroutine is already
+//sending it sends these packets too, after its own.
+func (sb *Scoreboard) unlock() {
+	if len(sb.pending) == 0 {
+		sb.lock.Unlock()
+		return
+	}
+	pending := sb.pending
+	sb.pending = nil
+	//Added before unlocking so later changes are sent after these
+	sb.sendLock.Lock()
+	sb.outbox = append(sb.outbox, pending...)
+	if sb.sending {
+		sb.sendLock.Unlock()
+		sb.lock.Unlock()
+		return
+	}
+	sb.sending = true
+	sb.sendLock.Unlock()
+	sb.lock.Unlock()
+	for {
+		sb.sendLock.Lock()
+		outbox := sb.outbox
+		sb.outbox = nil
+		if len(outbox) == 0 {
+			sb.sending = false
+			sb.sendLock.Unlock()
+			return
+		}
+		sb.sendLock.Unlock()
+		for _, q := range outbox {
+			q.v.QueuePacket(q.packet)
+		}
+	}
+}
+
+//AddViewer sends the current state of the scoreboard to the viewer and
+//keeps it in sync until it is removed
+func (sb *Scoreboard) AddViewer(v Viewer) {
+	sb.lock.Lock()
+	defer sb.unlock()
+	if sb.viewers[v] {
+		return
+	}
+	sb.viewers[v] = true
+	for _, o := range sb.objectives {
+		sb.send(v, o.packet(0))
+		for item, value := range o.scores {
+			sb.send(v, o.scorePacket(item, value))
+		}
+	}
+	for slot, o := range sb.display {
+		if o != nil {
+			sb.send(v, protocol.DisplayScoreboard{
+				Position:      int8(slot),
+				ObjectiveName: o.name,
+			})
+		}
+	}
+	for _, t := range sb.teams {
+		sb.send(v, t.packet(0, t.playerList()))
+	}
+}
+
+//RemoveViewer stops sending changes to the viewer and removes the
+//scoreboard from the viewer's client
+func (sb *Scoreboard) RemoveViewer(v Viewer) {
+	sb.lock.Lock()
+	defer sb.unlock()
+	if !sb.viewers[v] {
+		return
+	}
+	delete(sb.viewers, v)
+	//Removing an objective also removes its scores and display slots
+	for _, o := range sb.objectives {
+		sb.send(v, o.packet(1))
+	}
+	for _, t := range sb.teams {
+		sb.send(v, protocol.Teams{Name: t.name, Mode: 1})
+	}
+}
+
+//DropViewer stops sending changes to the viewer without sending anything
+//to it. This should be used for viewers that have disconnected.
+func (sb *Scoreboard) DropViewer(v Viewer) {
+	sb.lock.Lock()
+	defer sb.unlock()
+	delete(sb.viewers, v)
+}
+
+//AddObjective creates a new objective. The display name is shown to
+//players and is cut to 32 characters.
+func (sb *Scoreboard) AddObjective(name, displayName string) (*Objective, error) {
+	if len(name) > maxNameLength {
+		return nil, ErrorNameTooLong
+	}
+	sb.lock.Lock()
+	defer sb.unlock()
+	if _, ok := sb.objectives[name]; ok {
+		return nil, ErrorExists
+	}
+	o := &Objective{
+		board:       sb,
+		name:        name,
+		displayName: cut(displayName, maxDisplayNameLength),
+		scores:      map[string]int32{},
+	}
+	sb.objectives[name] = o
+	sb.broadcast(o.packet(0))
+	return o, nil
+}
+
+//Objective returns the named objective or nil if it doesn't exist
+func (sb *Scoreboard) Objective(name string) *Objective {
+	sb.lock.Lock()
+	defer sb.unlock()
+	return sb.objectives[name]
+}
+
+//RemoveObjective removes the named objective along with its scores
+func (sb *Scoreboard) RemoveObjective(name string) {
+	sb.lock.Lock()
+	defer sb.unlock()
+	o, ok := sb.objectives[name]
+	if !ok {
+		return
+	}
+	delete(sb.objectives, name)
+	o.removed = true
+	for slot := range sb.display {
+		if sb.display[slot] == o {
+			sb.display[slot] = nil
+		}
+	}
+	sb.broadcast(o.packet(1))
+}
+
+//SetDisplay shows the objective in the slot. A nil objective clears the
+//slot.
+func (sb *Scoreboard) SetDisplay(slot Slot, o *Objective) {
+	sb.lock.Lock()
+	defer sb.unlock()
+	if o != nil && (o.board != sb || o.removed) {
+		return
+	}
+	if sb.display[slot] == o {
+		return
+	}
+	sb.display[slot] = o
+	name := ""
+	if o != nil {
+		name = o.name
+	}
+	sb.broadcast(protocol.DisplayScoreboard{
+		Position:      int8(slot),
+		ObjectiveName: name,
+	})
+}
+
+//Display returns the objective shown in the slot or nil if the slot
+//is empty
+func (sb *Scoreboard) Display(slot Slot) *Objective {
+	sb.lock.Lock()
+	defer sb.unlock()
+	return sb.display[slot]
+}
+
+//RemoveItem removes the item's score from every objective
+func (sb *Scoreboard) RemoveItem(item string) {
+	item = cut(item, maxNameLength)
+	sb.lock.Lock()
+	defer sb.unlock()
+	found := false
+	for _, o := range sb.objectives {
+		if _, ok := o.scores[item]; ok {
+			delete(o.scores, item)
+			found = true
+		}
+	}
+	if found {
+		sb.broadcast(protocol.UpdateScore{ItemName: item, Mode: 1})
+	}
+}
+
+//An Objective tracks a score for a set of items (normally player names)
+type Objective struct {
+	board       *Scoreboard
+	name        string
+	displayName string
+	scores      map[string]int32
+	removed     bool
+}
+
+//Returns the objective packet with the mode. The lock must be held
+func (o *Objective) packet(mode int8) protocol.ScoreboardObjective {
+	return protocol.ScoreboardObjective{
+		Name:  o.name,
+		Value: o.displayName,
+		Mode:  mode,
+	}
+}
+
+//Returns the packet that sets the item's score. The lock must be held
+func (o *Objective) scorePacket(item string, value int32) protocol.UpdateScore {
+	return protocol.UpdateScore{
+		ItemName:      item,
+		Mode:          0,
+		ObjectiveName: o.name,
+		Value:         value,
+	}
+}
+
+//Name returns the name of the objective
+func (o *Objective) Name() string {
+	return o.name
+}
+
+//DisplayName returns the name shown to players
+func (o *Objective) DisplayName() string {
+	o.board.lock.Lock()
+	defer o.board.unlock()
+	return o.displayName
+}
+
+//SetDisplayName changes the name shown to players. The name is cut to
+//32 characters.
+func (o *Objective) SetDisplayName(displayName string) {
+	displayName = cut(displayName, maxDisplayNameLength)
+	o.board.lock.Lock()
+	defer o.board.unlock()
+	if o.removed || o.displayName == displayName {
+		return
+	}
+	o.displayName = displayName
+	o.board.broadcast(o.packet(2))
+}
+
+//Score returns the item's score and whether it has one
+func (o *Objective) Score(item string) (int32, bool) {
+	o.board.lock.Lock()
+	defer o.board.unlock()
+	value, ok := o.scores[item]
+	return value, ok
+}
+
+//SetScore sets the item's score. The item is cut to 16 characters.
+func (o *Objective) SetScore(item string, value int32) {
+	item = cut(item, maxNameLength)
+	o.board.lock.Lock()
+	defer o.board.unlock()
+	o.setScore(item, value)
+}
+
+//AddScore adds the delta to the item's score and returns the new
+//score. Items without a score start at 0.
+func (o *Objective) AddScore(item string, delta int32) int32 {
+	item = cut(item, maxNameLength)
+	o.board.lock.Lock()
+	defer o.board.unlock()
+	value := o.scores[item] + delta
+	o.setScore(item, value)
+	return value
+}
+
+func (o *Objective) setScore(item string, value int32) {
+	if o.removed {
+		return
+	}
+	if old, ok := o.scores[item]; ok && old == value {
+		return
+	}
+	o.scores[item] = value
+	o.board.broadcast(o.scorePacket(item, value))
+}
+
+//RemoveScore removes the item's score from the objective
+func (o *Objective) RemoveScore(item string) {
+	item = cut(item, maxNameLength)
+	o.board.lock.Lock()
+	defer o.board.unlock()
+	if _, ok := o.scores[item]; o.removed || !ok {
+		return
+	}
+	delete(o.scores, item)
+	//The client removes the item from every objective so the
+	//other scores have to be sent again
+	o.board.broadcast(protocol.UpdateScore{ItemName: item, Mode: 1})
+	for _, other := range o.board.objectives {
+		if value, ok := other.scores[item]; ok {
+			o.board.broadcast(other.scorePacket(item, value))
+		}
+	}
+}
+
+//Cuts the string to the length
+func cut(str string, length int) string {
+	if len(str) > length {
+		return str[:length]
+	}
+	return str
+}
@@ -0,0 +1,208 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package scoreboard
+
+import (
+	"bytes"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//Records the packets it is sent after checking they survive being
+//encoded and decoded
+type testViewer struct {
+	t       *testing.T
+	packets []protocol.Packet
+}
+
+func (v *testViewer) QueuePacket(packet protocol.Packet) {
+	var buf bytes.Buffer
+	conn := &protocol.Conn{
+		In:             &buf,
+		Out:            &buf,
+		State:          protocol.Play,
+		ReadDirection:  protocol.Clientbound,
+		WriteDirection: protocol.Clientbound,
+	}
+	if err := conn.WritePacket(packet); err != nil {
+		v.t.Fatal(err)
+	}
+	decoded, err := conn.ReadPacket()
+	if err != nil {
+		v.t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, packet) {
+		v.t.Fatalf("Packet changed by encoding: %#v != %#v", decoded, packet)
+	}
+	v.packets = append(v.packets, packet)
+}
+
+func (v *testViewer) take() []protocol.Packet {
+	packets := v.packets
+	v.packets = nil
+	return packets
+}
+
+func TestScoreDiffs(t *testing.T) {
+	sb := New()
+	v := &testViewer{t: t}
+	sb.AddViewer(v)
+
+	o, err := sb.AddObjective("kills", "Kills")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sb.AddObjective("kills", "Kills"); err != ErrorExists {
+		t.Fatalf("Expected ErrorExists, got %v", err)
+	}
+	sb.SetDisplay(Sidebar, o)
+	o.SetScore("alice", 3)
+	o.SetScore("alice", 3)
+	if got := o.AddScore("alice", 2); got != 5 {
+		t.Fatalf("Expected 5, got %d", got)
+	}
+	want := []protocol.Packet{
+		protocol.ScoreboardObjective{Name: "kills", Value: "Kills", Mode: 0},
+		protocol.DisplayScoreboard{Position: 1, ObjectiveName: "kills"},
+		protocol.UpdateScore{ItemName: "alice", ObjectiveName: "kills", Value: 3},
+		protocol.UpdateScore{ItemName: "alice", ObjectiveName: "kills", Value: 5},
+	}
+	if got := v.take(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Unexpected packets:\n%#v\nwant\n%#v", got, want)
+	}
+
+	o.RemoveScore("alice")
+	want = []protocol.Packet{
+		protocol.UpdateScore{ItemName: "alice", Mode: 1},
+	}
+	if got := v.take(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Unexpected packets:\n%#v\nwant\n%#v", got, want)
+	}
+}
+
+func TestTeams(t *testing.T) {
+	sb := New()
+	v := &testViewer{t: t}
+	sb.AddViewer(v)
+
+	red, _ := sb.AddTeam("red")
+	blue, _ := sb.AddTeam("blue")
+	red.SetPrefix("§c")
+	red.SetFriendlyFire(false)
+	red.AddPlayers("alice", "bob")
+	blue.AddPlayers("bob")
+	if sb.PlayerTeam("bob") != blue {
+		t.Fatal("Player not moved to the new team")
+	}
+	if got := red.Players(); !reflect.DeepEqual(got, []string{"alice"}) {
+		t.Fatalf("Unexpected players %v", got)
+	}
+	v.take()
+
+	//New viewers get the full state
+	late := &testViewer{t: t}
+	sb.AddViewer(late)
+	found := false
+	for _, packet := range late.take() {
+		if team, ok := packet.(protocol.Teams); ok && team.Name == "red" {
+			found = true
+			if team.Mode != 0 || team.Prefix != "§c" || team.Flags != 0 ||
+				!reflect.DeepEqual(team.Players, []string{"alice"}) {
+				t.Fatalf("Incorrect team sent: %#v", team)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("Team not sent to new viewer")
+	}
+
+	sb.RemoveViewer(late)
+	if got := len(late.take()); got != 2 {
+		t.Fatalf("Expected 2 team removals, got %d", got)
+	}
+	if got := len(v.take()); got != 0 {
+		t.Fatalf("Other viewer sent %d packets", got)
+	}
+}
+
+//Looks up objectives on the scoreboard it is viewing when sent packets
+type lookupViewer struct {
+	sb    *Scoreboard
+	found int
+}
+
+func (v *lookupViewer) QueuePacket(packet protocol.Packet) {
+	if o, ok := packet.(protocol.ScoreboardObjective); ok && v.sb.Objective(o.Name) != nil {
+		v.found++
+	}
+}
+
+func TestViewerCallsScoreboard(t *testing.T) {
+	sb := New()
+	v := &lookupViewer{sb: sb}
+	sb.AddViewer(v)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sb.AddObjective("kills", "Kills")
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Scoreboard deadlocked sending to a viewer")
+	}
+	if v.found != 1 {
+		t.Fatalf("Expected the objective to be found once, got %d", v.found)
+	}
+}
+
+//Adds a score whenever it is sent a new objective
+type changingViewer struct {
+	sb      *Scoreboard
+	packets []protocol.Packet
+}
+
+func (v *changingViewer) QueuePacket(packet protocol.Packet) {
+	v.packets = append(v.packets, packet)
+	if o, ok := packet.(protocol.ScoreboardObjective); ok && o.Mode == 0 {
+		v.sb.Objective(o.Name).SetScore("viewer", 1)
+	}
+}
+
+func TestViewerChangesScoreboard(t *testing.T) {
+	sb := New()
+	v := &changingViewer{sb: sb}
+	sb.AddViewer(v)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sb.AddObjective("kills", "Kills")
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Scoreboard deadlocked when a viewer changed it")
+	}
+	if len(v.packets) != 2 {
+		t.Fatalf("Expected the objective and then the score, got %#v", v.packets)
+	}
+	if _, ok := v.packets[1].(protocol.UpdateScore); !ok {
+		t.Fatalf("Expected the score to be sent after the objective, got %#v", v.packets)
+	}
+}
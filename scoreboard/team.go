@@ -0,0 +1,271 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package scoreboard
+
+import (
+	"github.com/NetherrackDev/netherrack/protocol"
+	"sort"
+)
+
+const (
+	//Allows players on the same team to attack each other
+	friendlyFireFlag = 1 << iota
+	//Lets players see team mates that are invisible
+	seeInvisibleFlag
+)
+
+//A Team groups players together. The prefix and suffix are added to
+//the names of the players on the team (e.g. to color them).
+type Team struct {
+	board       *Scoreboard
+	name        string
+	displayName string
+	prefix      string
+	suffix      string
+	flags       byte
+	players     map[string]bool
+	removed     bool
+}
+
+//AddTeam creates a new team with friendly fire enabled
+func (sb *Scoreboard) AddTeam(name string) (*Team, error) {
+	if len(name) > maxNameLength {
+		return nil, ErrorNameTooLong
+	}
+	sb.lock.Lock()
+	defer sb.unlock()
+	if _, ok := sb.teams[name]; ok {
+		return nil, ErrorExists
+	}
+	t := &Team{
+		board:       sb,
+		name:        name,
+		displayName: name,
+		flags:       friendlyFireFlag,
+		players:     map[string]bool{},
+	}
+	sb.teams[name] = t
+	sb.broadcast(t.packet(0, nil))
+	return t, nil
+}
+
+//Team returns the named team or nil if it doesn't exist
+func (sb *Scoreboard) Team(name string) *Team {
+	sb.lock.Lock()
+	defer sb.unlock()
+	return sb.teams[name]
+}
+
+//PlayerTeam returns the team the player is on or nil if they aren't
+//on one
+func (sb *Scoreboard) PlayerTeam(player string) *Team {
+	sb.lock.Lock()
+	defer sb.unlock()
+	return sb.itemTeams[player]
+}
+
+//RemoveTeam removes the named team
+func (sb *Scoreboard) RemoveTeam(name string) {
+	sb.lock.Lock()
+	defer sb.unlock()
+	t, ok := sb.teams[name]
+	if !ok {
+		return
+	}
+	delete(sb.teams, name)
+	for player := range t.players {
+		delete(sb.itemTeams, player)
+	}
+	t.removed = true
+	sb.broadcast(protocol.Teams{Name: t.name, Mode: 1})
+}
+
+//Returns the team packet with the mode. Only the fields sent for the
+//mode are filled. The lock must be held
+func (t *Team) packet(mode byte, players []string) protocol.Teams {
+	packet := protocol.Teams{
+		Name:    t.name,
+		Mode:    mode,
+		Players: players,
+	}
+	if mode == 0 || mode == 2 {
+		packet.DisplayName = t.displayName
+		packet.Prefix = t.prefix
+		packet.Suffix = t.suffix
+		packet.Flags = t.flags
+	}
+	return packet
+}
+
+//Sends the team's info to viewers. The lock must be held
+func (t *Team) update() {
+	t.board.broadcast(t.packet(2, nil))
+}
+
+//Name returns the name of the team
+func (t *Team) Name() string {
+	return t.name
+}
+
+//SetDisplayName changes the name of the team shown to players. The name
+//is cut to 32 characters.
+func (t *Team) SetDisplayName(displayName string) {
+	displayName = cut(displayName, maxDisplayNameLength)
+	t.board.lock.Lock()
+	defer t.board.unlock()
+	if t.removed || t.displayName == displayName {
+		return
+	}
+	t.displayName = displayName
+	t.update()
+}
+
+//SetPrefix changes the text shown before the names of players on the team.
+//The prefix is cut to 16 characters.
+func (t *Team) SetPrefix(prefix string) {
+	prefix = cut(prefix, maxNameLength)
+	t.board.lock.Lock()
+	defer t.board.unlock()
+	if t.removed || t.prefix == prefix {
+		return
+	}
+	t.prefix = prefix
+	t.update()
+}
+
+//SetSuffix changes the text shown after the names of players on the team.
+//The suffix is cut to 16 characters.
+func (t *Team) SetSuffix(suffix string) {
+	suffix = cut(suffix, maxNameLength)
+	t.board.lock.Lock()
+	defer t.board.unlock()
+	if t.removed || t.suffix == suffix {
+		return
+	}
+	t.suffix = suffix
+	t.update()
+}
+
+//SetFriendlyFire controls whether players on the team can attack each
+//other
+func (t *Team) SetFriendlyFire(enabled bool) {
+	t.setFlag(friendlyFireFlag, enabled)
+}
+
+//FriendlyFire returns whether players on the team can attack each other
+func (t *Team) FriendlyFire() bool {
+	return t.flag(friendlyFireFlag)
+}
+
+//SetSeeInvisible controls whether players on the team can see invisible
+//team mates
+func (t *Team) SetSeeInvisible(enabled bool) {
+	t.setFlag(seeInvisibleFlag, enabled)
+}
+
+//SeeInvisible returns whether players on the team can see invisible
+//team mates
+func (t *Team) SeeInvisible() bool {
+	return t.flag(seeInvisibleFlag)
+}
+
+func (t *Team) setFlag(flag byte, enabled bool) {
+	t.board.lock.Lock()
+	defer t.board.unlock()
+	flags := t.flags &^ flag
+	if enabled {
+		flags |= flag
+	}
+	if t.removed || t.flags == flags {
+		return
+	}
+	t.flags = flags
+	t.update()
+}
+
+func (t *Team) flag(flag byte) bool {
+	t.board.lock.Lock()
+	defer t.board.unlock()
+	return t.flags&flag != 0
+}
+
+//AddPlayers adds the players to the team. Players on another team are
+//removed from it first.
+func (t *Team) AddPlayers(players ...string) {
+	t.board.lock.Lock()
+	defer t.board.unlock()
+	if t.removed {
+		return
+	}
+	added := make([]string, 0, len(players))
+	for _, player := range players {
+		player = cut(player, maxNameLength)
+		old := t.board.itemTeams[player]
+		if old == t {
+			continue
+		}
+		if old != nil {
+			delete(old.players, player)
+			t.board.broadcast(old.packet(4, []string{player}))
+		}
+		t.board.itemTeams[player] = t
+		t.players[player] = true
+		added = append(added, player)
+	}
+	if len(added) > 0 {
+		t.board.broadcast(t.packet(3, added))
+	}
+}
+
+//RemovePlayers removes the players from the team
+func (t *Team) RemovePlayers(players ...string) {
+	t.board.lock.Lock()
+	defer t.board.unlock()
+	if t.removed {
+		return
+	}
+	removed := make([]string, 0, len(players))
+	for _, player := range players {
+		player = cut(player, maxNameLength)
+		if !t.players[player] {
+			continue
+		}
+		delete(t.players, player)
+		delete(t.board.itemTeams, player)
+		removed = append(removed, player)
+	}
+	if len(removed) > 0 {
+		t.board.broadcast(t.packet(4, removed))
+	}
+}
+
+//Players returns the players on the team
+func (t *Team) Players() []string {
+	t.board.lock.Lock()
+	defer t.board.unlock()
+	return t.playerList()
+}
+
+//Returns the sorted players on the team. The lock must be held
+func (t *Team) playerList() []string {
+	players := make([]string, 0, len(t.players))
+	for player := range t.players {
+		players = append(players, player)
+	}
+	sort.Strings(players)
+	return players
+}
@@ -0,0 +1,322 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package netherrack
+
+import (
+	"github.com/NetherrackDev/netherrack/entity/player"
+	"github.com/NetherrackDev/netherrack/protocol"
+	"strings"
+	"time"
+)
+
+const (
+	//The longest name the client will show in the player list
+	maxListNameLength = 16
+	//Prefix for the keys of fake entries so they can't clash
+	//with a player's uuid
+	fakeListPrefix = "fake:"
+)
+
+//The player list (shown whilst holding tab). The client identifies
+//entries by their name so changing the name of an entry removes the
+//old one first. Only accessed by the globalServer goroutine.
+type tabList struct {
+	//Keyed by uuid
+	players map[string]*tabPlayer
+	//Fake entries shown to every player, keyed by name
+	fake map[string]int16
+	//Keyed by the viewer's uuid
+	views map[string]*tabView
+}
+
+type tabPlayer struct {
+	p      *player.Player
+	name   string
+	ping   int16
+	online bool
+}
+
+//A single player's view of the list
+type tabView struct {
+	p      *player.Player
+	names  map[string]string
+	hidden map[string]bool
+	fake   map[string]int16
+	//What the client currently has
+	shown map[string]tabItem
+}
+
+type tabItem struct {
+	name string
+	ping int16
+}
+
+func newTabList() *tabList {
+	return &tabList{
+		players: map[string]*tabPlayer{},
+		fake:    map[string]int16{},
+		views:   map[string]*tabView{},
+	}
+}
+
+//Returns the list entry for the player, creating it if the player
+//hasn't been added yet
+func (t *tabList) player(p *player.Player) *tabPlayer {
+	tp, ok := t.players[p.UUID()]
+	if !ok {
+		tp = &tabPlayer{p: p}
+		if !hasLeft(p) {
+			t.players[p.UUID()] = tp
+		}
+	}
+	return tp
+}
+
+//Returns the player's view of the list, creating it if the player
+//hasn't been added yet
+func (t *tabList) view(p *player.Player) *tabView {
+	v, ok := t.views[p.UUID()]
+	if !ok {
+		v = &tabView{
+			p:      p,
+			names:  map[string]string{},
+			hidden: map[string]bool{},
+			fake:   map[string]int16{},
+			shown:  map[string]tabItem{},
+		}
+		if !hasLeft(p) {
+			t.views[p.UUID()] = v
+		}
+	}
+	return v
+}
+
+//Returns whether the player has disconnected. Changes to players that
+//have left are dropped instead of being kept forever.
+func hasLeft(p *player.Player) bool {
+	select {
+	case <-p.ClosedChannel:
+		return true
+	default:
+		return false
+	}
+}
+
+//Shows the player in the list and sends the current list to them
+func (t *tabList) add(p *player.Player) {
+	tp := t.player(p)
+	tp.online = true
+	tp.ping = listPing(p.Ping())
+	t.refreshAll(p.UUID())
+
+	v := t.view(p)
+	for key := range t.players {
+		t.refresh(v, key)
+	}
+	for name := range t.fake {
+		t.refresh(v, fakeListPrefix+name)
+	}
+	for name := range v.fake {
+		t.refresh(v, fakeListPrefix+name)
+	}
+}
+
+//Removes the player from the list and forgets any changes
+//made to it
+func (t *tabList) remove(p *player.Player) {
+	uuid := p.UUID()
+	delete(t.players, uuid)
+	delete(t.views, uuid)
+	for _, v := range t.views {
+		delete(v.names, uuid)
+		delete(v.hidden, uuid)
+		t.refresh(v, uuid)
+	}
+}
+
+//Updates the entry with the key for every viewer
+func (t *tabList) refreshAll(key string) {
+	for _, v := range t.views {
+		t.refresh(v, key)
+	}
+}
+
+//Sends the changes (if any) to the entry with the key to the viewer
+func (t *tabList) refresh(v *tabView, key string) {
+	if viewer, ok := t.players[v.p.UUID()]; !ok || !viewer.online {
+		return
+	}
+	item, ok := t.entry(v, key)
+	shown, wasShown := v.shown[key]
+	if wasShown && (!ok || shown.name != item.name) {
+		v.p.QueuePacket(protocol.PlayerListItem{
+			PlayerName: shown.name,
+			Online:     false,
+		})
+		delete(v.shown, key)
+		wasShown = false
+	}
+	if ok && (!wasShown || shown.ping != item.ping) {
+		v.p.QueuePacket(protocol.PlayerListItem{
+			PlayerName: item.name,
+			Online:     true,
+			Ping:       item.ping,
+		})
+		v.shown[key] = item
+	}
+}
+
+//Returns what the viewer should see for the entry with the key
+func (t *tabList) entry(v *tabView, key string) (tabItem, bool) {
+	if strings.HasPrefix(key, fakeListPrefix) {
+		name := key[len(fakeListPrefix):]
+		if ping, ok := v.fake[name]; ok {
+			return tabItem{name, ping}, true
+		}
+		ping, ok := t.fake[name]
+		return tabItem{name, ping}, ok
+	}
+	tp, ok := t.players[key]
+	if !ok || !tp.online || v.hidden[key] {
+		return tabItem{}, false
+	}
+	name, ok := v.names[key]
+	if !ok {
+		name = tp.name
+	}
+	if name == "" {
+		name = tp.p.Username
+	}
+	return tabItem{name, tp.ping}, true
+}
+
+//Converts the ping into the value the client expects
+func listPing(ping time.Duration) int16 {
+	ms := ping / time.Millisecond
+	if ms > 1<<15-1 {
+		ms = 1<<15 - 1
+	}
+	return int16(ms)
+}
+
+//Cuts the name to the length the client supports
+func listName(name string) string {
+	if len(name) > maxListNameLength {
+		name = name[:maxListNameLength]
+	}
+	return name
+}
+
+//Runs the function on the globalServer goroutine
+func (server *Server) updateTabList(f func(t *tabList)) {
+	server.global.tabList <- f
+}
+
+//UpdateListPing updates the ping shown for the player in the player list.
+//This is called by the player once its ping changes.
+func (server *Server) UpdateListPing(p *player.Player) {
+	ping := listPing(p.Ping())
+	server.updateTabList(func(t *tabList) {
+		tp, ok := t.players[p.UUID()]
+		if !ok || tp.ping == ping {
+			return
+		}
+		tp.ping = ping
+		t.refreshAll(p.UUID())
+	})
+}
+
+//SetListName changes the name shown for the player in the player list.
+//Names are limited to 16 characters and an empty name resets it to the
+//player's username. Names should be unique otherwise the entries will
+//replace each other.
+func (server *Server) SetListName(p *player.Player, name string) {
+	name = listName(name)
+	server.updateTabList(func(t *tabList) {
+		t.player(p).name = name
+		t.refreshAll(p.UUID())
+	})
+}
+
+//SetListNameFor changes the name shown for the player only in the viewer's
+//player list. An empty name removes the change.
+func (server *Server) SetListNameFor(viewer, p *player.Player, name string) {
+	name = listName(name)
+	server.updateTabList(func(t *tabList) {
+		v := t.view(viewer)
+		if name == "" {
+			delete(v.names, p.UUID())
+		} else {
+			v.names[p.UUID()] = name
+		}
+		t.refresh(v, p.UUID())
+	})
+}
+
+//SetListHidden hides or shows the player in the viewer's player list
+func (server *Server) SetListHidden(viewer, p *player.Player, hidden bool) {
+	server.updateTabList(func(t *tabList) {
+		v := t.view(viewer)
+		if hidden {
+			v.hidden[p.UUID()] = true
+		} else {
+			delete(v.hidden, p.UUID())
+		}
+		t.refresh(v, p.UUID())
+	})
+}
+
+//AddFakeListEntry adds an entry that isn't linked to a player to every
+//player's list. Adding an entry that already exists updates its ping.
+func (server *Server) AddFakeListEntry(name string, ping int16) {
+	name = listName(name)
+	server.updateTabList(func(t *tabList) {
+		t.fake[name] = ping
+		t.refreshAll(fakeListPrefix + name)
+	})
+}
+
+//RemoveFakeListEntry removes an entry added by AddFakeListEntry
+func (server *Server) RemoveFakeListEntry(name string) {
+	name = listName(name)
+	server.updateTabList(func(t *tabList) {
+		delete(t.fake, name)
+		t.refreshAll(fakeListPrefix + name)
+	})
+}
+
+//AddFakeListEntryFor adds an entry that isn't linked to a player to only
+//the viewer's player list. This replaces any entry added by AddFakeListEntry
+//with the same name for the viewer.
+func (server *Server) AddFakeListEntryFor(viewer *player.Player, name string, ping int16) {
+	name = listName(name)
+	server.updateTabList(func(t *tabList) {
+		v := t.view(viewer)
+		v.fake[name] = ping
+		t.refresh(v, fakeListPrefix+name)
+	})
+}
+
+//RemoveFakeListEntryFor removes an entry added by AddFakeListEntryFor
+func (server *Server) RemoveFakeListEntryFor(viewer *player.Player, name string) {
+	name = listName(name)
+	server.updateTabList(func(t *tabList) {
+		v := t.view(viewer)
+		delete(v.fake, name)
+		t.refresh(v, fakeListPrefix+name)
+	})
+}
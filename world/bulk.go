@@ -0,0 +1,63 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package world
+
+import (
+	"github.com/NetherrackDev/netherrack/protocol"
+)
+
+//Optionally implemented by watchers that want to group chunks into
+//MapChunkBulk packets instead of being sent a ChunkData for each one
+type ChunkBatcher interface {
+	//Queues the chunk's uncompressed data to be sent to the watcher
+	QueueChunk(chunk RawChunk)
+}
+
+//A chunk's data before compression. The data includes sky light.
+type RawChunk struct {
+	X, Z          int32
+	PrimaryBitMap uint16
+	Data          []byte
+}
+
+//Compresses the chunks together into a single MapChunkBulk packet
+func (world *World) BulkPacket(chunks []RawChunk) protocol.MapChunkBulk {
+	zl := <-world.SendLimiter
+	defer func() { world.SendLimiter <- zl }()
+	buf, w := zl.buf, zl.zl
+	buf.Reset()
+	w.Reset(buf)
+	meta := make([]protocol.ChunkMeta, len(chunks))
+	for i, chunk := range chunks {
+		w.Write(chunk.Data)
+		meta[i] = protocol.ChunkMeta{
+			X:          chunk.X,
+			Z:          chunk.Z,
+			PrimaryBit: chunk.PrimaryBitMap,
+		}
+	}
+	w.Close()
+	data := make([]byte, buf.Len())
+	copy(data, buf.Bytes())
+	return protocol.MapChunkBulk{
+		ChunkCount: int16(len(chunks)),
+		DataLength: int32(len(data)),
+		SkyLight:   1,
+		Data:       data,
+		Meta:       meta,
+	}
+}
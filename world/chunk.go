@@ -17,9 +17,11 @@
 package world
 
 import (
+	"bytes"
 	"encoding/binary"
 	"github.com/NetherrackDev/netherrack/blocks"
 	"github.com/NetherrackDev/netherrack/protocol"
+	"io"
 	"time"
 )
 
@@ -45,6 +47,26 @@ type Entity interface {
 	Saveable() bool
 }
 
+//Optionally implemented by saveable entities that change whilst in the
+//chunk. The copy is saved instead of the entity. It is taken when the
+//entity is added so AddEntity must be called from the entity's goroutine.
+type Snapshotter interface {
+	Snapshot() Entity
+}
+
+//Implemented by saved entities that start again once their chunk is
+//loaded. Load must not block.
+type Loadable interface {
+	Load(world *World)
+}
+
+//Optionally implemented by entities that stop when their chunk is
+//unloaded. Unload must not block, the entity should call UnloadEntity
+//once it has stopped.
+type Unloadable interface {
+	Unload()
+}
+
 //A chunk loaded locally in a flat byte arrays
 type Chunk struct {
 	X, Z   int
@@ -58,20 +80,27 @@ type Chunk struct {
 
 	blockChanges []blockChange
 
-	join              chan Watcher
-	leave             chan Watcher
-	blockPlace        chan blockChange
-	blockGet          chan blockGet
-	light             chan lightEvent
-	chunkPacket       chan chunkPacket
-	entity            chan entityChunk
-	entitySpawnUpdate chan packetUpdate
-
-	watchers        map[string]Watcher
-	entities        map[string]Entity
-	Entities        map[string]Entity
-	entitySpawnData map[string]spawnData
-	closeChannel    chan chan bool
+	join        chan Watcher
+	leave       chan Watcher
+	blockPlace  chan blockChange
+	blockGet    chan blockGet
+	heightGet   chan heightGet
+	light       chan lightEvent
+	chunkPacket chan chunkPacket
+	entity      chan entityChunk
+
+	watchers     map[string]Watcher
+	entities     map[string]Entity
+	Entities     map[string]Entity
+	closeChannel chan chan bool
+
+	//Saved entities that have been loaded but haven't been added yet
+	loading map[string]bool
+
+	//Set whilst the world has a request to close the chunk
+	closeRequested bool
+	//Set when the world was too busy to take a close request
+	retryClose bool
 
 	lightChan     chan lightRequest
 	lightComplete chan struct{}
@@ -88,11 +117,6 @@ type ChunkSection struct {
 	Count uint
 }
 
-type spawnData struct {
-	spawn   []protocol.Packet
-	despawn []protocol.Packet
-}
-
 //Inits the chunk. Should only be called by the world
 func (c *Chunk) Init(world *World, gen Generator, system System) {
 	c.world = world
@@ -102,15 +126,17 @@ func (c *Chunk) Init(world *World, gen Generator, system System) {
 
 	c.blockPlace = make(chan blockChange, 50)
 	c.blockGet = make(chan blockGet, 50)
+	c.heightGet = make(chan heightGet, 50)
 	c.light = make(chan lightEvent, 50)
 
 	c.chunkPacket = make(chan chunkPacket, 50)
 	c.entity = make(chan entityChunk, 50)
-	c.entitySpawnUpdate = make(chan packetUpdate, 50)
 	c.watchers = make(map[string]Watcher)
 	c.entities = make(map[string]Entity)
-	c.entitySpawnData = make(map[string]spawnData)
-	c.Entities = make(map[string]Entity)
+	if c.Entities == nil {
+		c.Entities = make(map[string]Entity)
+	}
+	c.loading = make(map[string]bool)
 	c.closeChannel = make(chan chan bool)
 	c.lightComplete = make(chan struct{})
 	go c.run(gen)
@@ -124,32 +150,30 @@ func (c *Chunk) run(gen Generator) {
 		<-c.world.SaveLimiter
 		c.system.SaveChunk(c.X, c.Z, c)
 		c.world.SaveLimiter <- struct{}{}
+	} else {
+		c.loadEntities()
 	}
 	c.genComplete = true
 	t := time.NewTicker(5 * time.Minute)
-	var blockUpdate <-chan time.Time
+	var blockUpdate, closeRetry <-chan time.Time
 	defer t.Stop()
 	for {
+		if c.retryClose && closeRetry == nil {
+			closeRetry = time.After(time.Second / 10)
+		}
 		select {
+		case <-closeRetry:
+			closeRetry = nil
+			c.retryClose = false
+			c.tryClose()
 		case <-c.lightComplete:
 			if len(c.lightChan) > 0 {
 				go lightWorker(c.lightChan, c.lightComplete)
 				continue
 			}
 			c.lightChan = nil
-			zl := <-c.world.SendLimiter
-			data, primaryBitMap := c.genPacketData(zl)
-			packet := protocol.ChunkData{
-				X: int32(c.X), Z: int32(c.Z),
-				GroundUp:       true,
-				PrimaryBitMap:  primaryBitMap,
-				CompressedData: data,
-			}
-
-			for _, watcher := range waitingForChunk {
-				watcher.QueuePacket(packet)
-			}
-			c.world.SendLimiter <- zl
+			c.sendChunk(waitingForChunk)
+			waitingForChunk = waitingForChunk[:0]
 		case <-blockUpdate:
 			blockUpdate = nil
 			data := make([]byte, len(c.blockChanges)*4)
@@ -176,6 +200,8 @@ func (c *Chunk) run(gen Generator) {
 				c.needsSave = false
 				c.system.SaveChunk(c.X, c.Z, c)
 				c.world.SaveLimiter <- struct{}{}
+				//Closing waits for unsaved changes
+				c.tryClose()
 			}
 		case watcher := <-c.join:
 			watchers := make([]Watcher, 0, 1)
@@ -192,31 +218,10 @@ func (c *Chunk) run(gen Generator) {
 				}
 			}
 			if c.lightChan == nil {
-				zl := <-c.world.SendLimiter
-				data, primaryBitMap := c.genPacketData(zl)
-				packet := protocol.ChunkData{
-					X: int32(c.X), Z: int32(c.Z),
-					GroundUp:       true,
-					PrimaryBitMap:  primaryBitMap,
-					CompressedData: data,
-				}
-
-				for _, watcher := range watchers {
-					watcher.QueuePacket(packet)
-				}
-				c.world.SendLimiter <- zl
+				c.sendChunk(watchers)
 			} else {
 				waitingForChunk = append(waitingForChunk, watchers...)
 			}
-			for _, watcher := range watchers {
-				for uuid, e := range c.entitySpawnData {
-					if watcher.UUID() != uuid {
-						for _, p := range e.spawn {
-							watcher.QueuePacket(p)
-						}
-					}
-				}
-			}
 		case watcher := <-c.leave:
 			delete(c.watchers, watcher.UUID())
 			watcher.QueuePacket(protocol.ChunkData{
@@ -224,18 +229,7 @@ func (c *Chunk) run(gen Generator) {
 				GroundUp:       true,
 				CompressedData: []byte{},
 			})
-			for uuid, e := range c.entitySpawnData {
-				if watcher.UUID() != uuid {
-					for _, p := range e.despawn {
-						watcher.QueuePacket(p)
-					}
-				}
-			}
-			if len(c.watchers) == 0 && c.lightChan == nil &&
-				len(c.light) == 0 &&
-				len(c.blockPlace) == 0 && len(c.blockGet) == 0 {
-				c.world.RequestClose <- c
-			}
+			c.tryClose()
 		case l := <-c.light:
 			x, z := l.X&0xF, l.Z&0xF
 			switch v := l.Value.(type) {
@@ -267,6 +261,8 @@ func (c *Chunk) run(gen Generator) {
 			block := c.Block(x, bg.Y, z)
 			data := c.Data(x, bg.Y, z)
 			bg.Ret <- [2]byte{block, data}
+		case hg := <-c.heightGet:
+			hg.Ret <- int(c.HeightMap[(hg.X&0xF)|((hg.Z&0xF)<<4)])
 		case packet := <-c.chunkPacket:
 			if packet.UUID != "" {
 				for _, w := range c.watchers {
@@ -280,43 +276,46 @@ func (c *Chunk) run(gen Generator) {
 				}
 			}
 		case ec := <-c.entity:
-			if ec.Add {
-				c.entities[ec.Entity.UUID()] = ec.Entity
+			uuid := ec.Entity.UUID()
+			switch {
+			case ec.Add:
+				c.entities[uuid] = ec.Entity
+				delete(c.loading, uuid)
 				if ec.Entity.Saveable() {
-					c.Entities[ec.Entity.UUID()] = ec.Entity
+					c.Entities[uuid] = ec.Saved
+					c.needsSave = true
 				}
-				sData := spawnData{
-					spawn:   ec.Spawn,
-					despawn: ec.Despawn,
+				//Entities in chunks nobody is watching are unloaded
+				if _, ok := ec.Entity.(Unloadable); ok {
+					c.tryClose()
 				}
-				c.entitySpawnData[ec.Entity.UUID()] = sData
-				for _, w := range c.watchers {
-					if w.UUID() != ec.Entity.UUID() {
-						for _, p := range sData.spawn {
-							w.QueuePacket(p)
-						}
-					}
+			case ec.Unload:
+				delete(c.entities, uuid)
+				if ec.Entity.Saveable() {
+					c.Entities[uuid] = ec.Saved
+					c.needsSave = true
 				}
-			} else {
-				delete(c.entities, ec.Entity.UUID())
-				sData := c.entitySpawnData[ec.Entity.UUID()]
-				delete(c.entitySpawnData, ec.Entity.UUID())
+				c.tryClose()
+			default:
+				delete(c.entities, uuid)
 				if ec.Entity.Saveable() {
-					delete(c.Entities, ec.Entity.UUID())
+					delete(c.Entities, uuid)
+					c.needsSave = true
 				}
-				for _, w := range c.watchers {
-					if w.UUID() != ec.Entity.UUID() {
-						for _, p := range sData.despawn {
-							w.QueuePacket(p)
-						}
-					}
+				//The chunk may have been waiting for the entity to leave
+				if _, ok := ec.Entity.(Unloadable); ok {
+					c.tryClose()
 				}
 			}
 		case ret := <-c.closeChannel:
-			if len(c.watchers) == 0 && len(c.join) == 0 && !c.needsSave &&
+			c.closeRequested = false
+			idle := len(c.watchers) == 0 && len(c.join) == 0 &&
+				len(c.entity) == 0 && len(c.loading) == 0 &&
 				c.lightChan == nil &&
 				len(c.light) == 0 &&
-				len(c.blockPlace) == 0 && len(c.blockGet) == 0 {
+				len(c.blockPlace) == 0 && len(c.blockGet) == 0 &&
+				len(c.heightGet) == 0
+			if idle && !c.unloadEntities() && !c.needsSave {
 				c.system.CloseChunk(c.X, c.Z, c)
 				ret <- true
 				return
@@ -326,18 +325,53 @@ func (c *Chunk) run(gen Generator) {
 				t.Stop()
 				t = time.NewTicker(100 * time.Millisecond)
 			}
-		case update := <-c.entitySpawnUpdate:
-			sData := c.entitySpawnData[update.entity.UUID()]
-			if update.spawn {
-				sData.spawn = update.packets
-			} else {
-				sData.despawn = update.packets
-			}
-			c.entitySpawnData[update.entity.UUID()] = sData
 		}
 	}
 }
 
+//Asks the world to close the chunk if nobody is watching it. The
+//world may be blocked sending to this chunk so the request is retried
+//shortly instead of waiting for it to be taken.
+func (c *Chunk) tryClose() {
+	if c.closeRequested {
+		return
+	}
+	if len(c.watchers) == 0 && c.lightChan == nil &&
+		len(c.light) == 0 &&
+		len(c.blockPlace) == 0 && len(c.blockGet) == 0 &&
+		len(c.heightGet) == 0 {
+		select {
+		case c.world.RequestClose <- c:
+			c.closeRequested = true
+		default:
+			c.retryClose = true
+		}
+	}
+}
+
+//Starts the saved entities that can be loaded
+func (c *Chunk) loadEntities() {
+	for uuid, e := range c.Entities {
+		if l, ok := e.(Loadable); ok {
+			c.loading[uuid] = true
+			l.Load(c.world)
+		}
+	}
+}
+
+//Asks the entities in the chunk that can be unloaded to stop, returns
+//whether there were any
+func (c *Chunk) unloadEntities() bool {
+	unloading := false
+	for _, e := range c.entities {
+		if u, ok := e.(Unloadable); ok {
+			u.Unload()
+			unloading = true
+		}
+	}
+	return unloading
+}
+
 func (c *Chunk) postLightRequest(req lightRequest) {
 	if !c.genComplete {
 		return
@@ -597,16 +631,60 @@ func (c *Chunk) SkyLight(x, y, z int) byte {
 	return d >> 4
 }
 
+//Sends the chunk's data to the watchers. Watchers that batch chunks
+//are given the uncompressed data instead of a ChunkData packet.
+func (c *Chunk) sendChunk(watchers []Watcher) {
+	var packet *protocol.ChunkData
+	var raw *RawChunk
+	for _, watcher := range watchers {
+		if batcher, ok := watcher.(ChunkBatcher); ok {
+			if raw == nil {
+				buf := &bytes.Buffer{}
+				mask := c.writeData(buf)
+				raw = &RawChunk{
+					X: int32(c.X), Z: int32(c.Z),
+					PrimaryBitMap: mask,
+					Data:          buf.Bytes(),
+				}
+			}
+			batcher.QueueChunk(*raw)
+			continue
+		}
+		if packet == nil {
+			zl := <-c.world.SendLimiter
+			data, primaryBitMap := c.genPacketData(zl)
+			c.world.SendLimiter <- zl
+			packet = &protocol.ChunkData{
+				X: int32(c.X), Z: int32(c.Z),
+				GroundUp:       true,
+				PrimaryBitMap:  primaryBitMap,
+				CompressedData: data,
+			}
+		}
+		watcher.QueuePacket(*packet)
+	}
+}
+
 func (c *Chunk) genPacketData(cache cachedCompressor) ([]byte, uint16) {
-	var mask uint16
 	buf, zl := cache.buf, cache.zl
 	buf.Reset()
 	zl.Reset(buf)
+	mask := c.writeData(zl)
+	zl.Flush()
+	ret := make([]byte, buf.Len())
+	copy(ret, buf.Bytes())
+	return ret, mask
+}
+
+//Writes the chunk's sections in the format used by ChunkData and
+//MapChunkBulk and returns the bitmask of the sections written
+func (c *Chunk) writeData(w io.Writer) uint16 {
+	var mask uint16
 	for i, sec := range c.Sections {
 		if sec == nil {
 			continue
 		}
-		zl.Write(sec.Blocks[:])
+		w.Write(sec.Blocks[:])
 		mask |= 1 << uint(i)
 	}
 
@@ -614,27 +692,24 @@ func (c *Chunk) genPacketData(cache cachedCompressor) ([]byte, uint16) {
 		if sec == nil {
 			continue
 		}
-		zl.Write(sec.Data[:])
+		w.Write(sec.Data[:])
 	}
 
 	for _, sec := range c.Sections {
 		if sec == nil {
 			continue
 		}
-		zl.Write(sec.BlockLight[:])
+		w.Write(sec.BlockLight[:])
 	}
 
 	for _, sec := range c.Sections {
 		if sec == nil {
 			continue
 		}
-		zl.Write(sec.SkyLight[:])
+		w.Write(sec.SkyLight[:])
 	}
-	zl.Write(c.Biome[:])
-	zl.Flush()
-	ret := make([]byte, buf.Len())
-	copy(ret, buf.Bytes())
-	return ret, mask
+	w.Write(c.Biome[:])
+	return mask
 }
 
 func (c *Chunk) Close() bool {
@@ -0,0 +1,129 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package world
+
+import (
+	"github.com/NetherrackDev/netherrack/blocks"
+)
+
+const (
+	//How far from the origin new worlds look for a spawn
+	spawnSearchRadius = 32
+	//Where the spawn is put if nowhere safe is found
+	defaultSpawnY = 70
+	//Nether spawns are searched for below the bedrock ceiling
+	netherCeiling = 120
+)
+
+//Blocks players can't safely stand on
+var unsafeGround = map[byte]bool{
+	blocks.Air.ID:       true,
+	blocks.Lava.ID:      true,
+	blocks.LavaStill.ID: true,
+	blocks.Fire.ID:      true,
+	blocks.Cactus.ID:    true,
+}
+
+//A request to get or change the spawn. Both go through the same
+//channel so a spawn is never read before an earlier change is applied.
+type spawnRequest struct {
+	Set bool
+	Pos [3]int
+	Ret chan [3]int
+}
+
+//Returns the block position players spawn at
+func (world *World) Spawn() (x, y, z int) {
+	ret := make(chan [3]int, 1)
+	world.spawn <- spawnRequest{Ret: ret}
+	pos := <-ret
+	return pos[0], pos[1], pos[2]
+}
+
+//Changes where players spawn. The position is saved with the world.
+func (world *World) SetSpawn(x, y, z int) {
+	world.spawn <- spawnRequest{Set: true, Pos: [3]int{x, y, z}}
+}
+
+type heightGet struct {
+	X, Z int
+	Ret  chan int
+}
+
+//Returns the height of the highest block in the column
+func (world *World) Height(x, z int) int {
+	ret := make(chan int, 1)
+	world.getHeight <- heightGet{x, z, ret}
+	return <-ret
+}
+
+//Returns the highest position in the column a player can stand at
+//without being inside blocks or above the void. The search starts at
+//the top of the column, or below the ceiling in the nether.
+func (world *World) SafeHeight(x, z int) (y int, ok bool) {
+	top := world.Height(x, z)
+	if world.Dimension() == Nether && top > netherCeiling {
+		top = netherCeiling
+	}
+	above, head := world.blockAt(x, top+1, z), world.blockAt(x, top+2, z)
+	for y := top; y >= 0; y-- {
+		ground := world.blockAt(x, y, z)
+		if above == 0 && head == 0 && !unsafeGround[ground] {
+			return y + 1, true
+		}
+		head, above = above, ground
+	}
+	return 0, false
+}
+
+//Returns the block at the position, treating blocks outside of the
+//world as air
+func (world *World) blockAt(x, y, z int) byte {
+	if y < 0 || y > 255 {
+		return 0
+	}
+	block, _ := world.Block(x, y, z)
+	return block
+}
+
+//Searches for a safe position in squares growing out from x, z up to
+//radius blocks away.
+func (world *World) FindSafeSpawn(x, z, radius int) (sx, sy, sz int, ok bool) {
+	for r := 0; r <= radius; r++ {
+		for dx := -r; dx <= r; dx++ {
+			for dz := -r; dz <= r; dz++ {
+				//Only the edge of the square is new
+				if dx != -r && dx != r && dz != -r && dz != r {
+					continue
+				}
+				if y, ok := world.SafeHeight(x+dx, z+dz); ok {
+					return x + dx, y, z + dz, true
+				}
+			}
+		}
+	}
+	return 0, 0, 0, false
+}
+
+//Picks a spawn for a world that doesn't have one yet
+func (world *World) pickSpawn() {
+	x, y, z, ok := world.FindSafeSpawn(0, 0, spawnSearchRadius)
+	if !ok {
+		x, y, z = 0, defaultSpawnY, 0
+	}
+	world.SetSpawn(x, y, z)
+}
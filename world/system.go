@@ -91,6 +91,7 @@ func LoadWorld(name string, system System, gen Generator, dimension Dimension, t
 	w.generator.Save(w)
 	w.system.Write("levelData", &w.worldData)
 	go w.run()
+	w.pickSpawn()
 
 	return w
 }
@@ -133,7 +134,12 @@ func GetWorld(name string, tryClose chan TryClose) *World {
 	w.init()
 	w.system.Init(filepath.Join("./worlds/", w.Name))
 	w.system.Read("levelData", &w.worldData)
+	hasSpawn := w.worldData.SpawnSet
 	go w.run()
+	//Worlds saved before spawns were stored
+	if !hasSpawn {
+		w.pickSpawn()
+	}
 
 	return w
 }
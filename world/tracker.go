@@ -0,0 +1,241 @@
+/*
+   Copyright 2013 Matthew Collins (purggames@gmail.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package world
+
+import (
+	"github.com/NetherrackDev/netherrack/protocol"
+	"math"
+)
+
+//Kinds of entities that can be given their own tracking range
+type TrackingKind int
+
+const (
+	TrackOther TrackingKind = iota
+	TrackPlayer
+	TrackMob
+	TrackItem
+	TrackProjectile
+	TrackExperience
+)
+
+//How far away in blocks each kind of entity can be seen from. A viewer's
+//view distance limits this further.
+var trackingRanges = map[TrackingKind]float64{
+	TrackOther:      80,
+	TrackPlayer:     512,
+	TrackMob:        80,
+	TrackItem:       64,
+	TrackProjectile: 64,
+	TrackExperience: 160,
+}
+
+//Changes how far away in blocks the kind of entity can be seen from.
+//Should only be called at init
+func SetTrackingRange(kind TrackingKind, blocks int) {
+	trackingRanges[kind] = float64(blocks)
+}
+
+//Optionally implemented by entities to pick their tracking range,
+//TrackOther is used otherwise
+type Trackable interface {
+	TrackingKind() TrackingKind
+}
+
+type trackEntity struct {
+	entity      Entity
+	add, remove bool
+	X, Z        float64
+	//Spawn is left nil when updating to keep the current packets
+	spawn, despawn []protocol.Packet
+}
+
+type trackViewer struct {
+	watcher Watcher
+	remove  bool
+	X, Z    float64
+	//In chunks
+	distance int
+}
+
+type entityPacket struct {
+	uuid   string
+	packet protocol.Packet
+}
+
+//Starts showing the entity to viewers within its tracking range. Viewers
+//are sent the spawn packets when it comes into range and the despawn
+//packets when it goes out of range or stops being tracked.
+func (world *World) TrackEntity(entity Entity, x, z float64, spawn, despawn []protocol.Packet) {
+	world.trackEntity <- trackEntity{
+		entity:  entity,
+		add:     true,
+		X:       x,
+		Z:       z,
+		spawn:   spawn,
+		despawn: despawn,
+	}
+}
+
+//Updates the position of the entity. If spawn isn't nil it replaces the
+//entity's spawn packets. Entities that aren't tracked are ignored.
+func (world *World) UpdateTrackedEntity(entity Entity, x, z float64, spawn []protocol.Packet) {
+	world.trackEntity <- trackEntity{entity: entity, X: x, Z: z, spawn: spawn}
+}
+
+//Despawns the entity for every viewer that can see it
+func (world *World) UntrackEntity(entity Entity) {
+	world.trackEntity <- trackEntity{entity: entity, remove: true}
+}
+
+//Sends the packet to every viewer that can see the entity
+func (world *World) QueueEntityPacket(entity Entity, packet protocol.Packet) {
+	world.entityPacket <- entityPacket{entity.UUID(), packet}
+}
+
+//Starts showing the viewer the entities within range of the position.
+//distance is the viewer's view distance in chunks.
+func (world *World) AddViewer(watcher Watcher, x, z float64, distance int) {
+	world.trackViewer <- trackViewer{watcher: watcher, X: x, Z: z, distance: distance}
+}
+
+//Updates the position and view distance of the viewer
+func (world *World) MoveViewer(watcher Watcher, x, z float64, distance int) {
+	world.AddViewer(watcher, x, z, distance)
+}
+
+//Despawns every entity the viewer can see and stops tracking them
+func (world *World) RemoveViewer(watcher Watcher) {
+	world.trackViewer <- trackViewer{watcher: watcher, remove: true}
+}
+
+type trackedEntity struct {
+	entity         Entity
+	x, z           float64
+	trackingRange  float64
+	spawn, despawn []protocol.Packet
+	viewers        map[string]Watcher
+}
+
+type trackedViewer struct {
+	watcher Watcher
+	x, z    float64
+	//In blocks
+	distance float64
+}
+
+func (e *trackedEntity) visibleTo(v *trackedViewer) bool {
+	r := math.Min(e.trackingRange, v.distance)
+	return math.Abs(e.x-v.x) <= r && math.Abs(e.z-v.z) <= r
+}
+
+//Spawns or despawns the entity for the viewer if its visibility changed
+func (e *trackedEntity) update(v *trackedViewer) {
+	uuid := v.watcher.UUID()
+	if uuid == e.entity.UUID() {
+		return
+	}
+	_, tracking := e.viewers[uuid]
+	if visible := e.visibleTo(v); visible && !tracking {
+		e.viewers[uuid] = v.watcher
+		queuePackets(v.watcher, e.spawn)
+	} else if !visible && tracking {
+		delete(e.viewers, uuid)
+		queuePackets(v.watcher, e.despawn)
+	}
+}
+
+func queuePackets(watcher Watcher, packets []protocol.Packet) {
+	for _, packet := range packets {
+		watcher.QueuePacket(packet)
+	}
+}
+
+//Keeps track of which entities each viewer can see. Runs until stop is
+//closed.
+func (world *World) runTracker(stop chan struct{}) {
+	entities := map[string]*trackedEntity{}
+	viewers := map[string]*trackedViewer{}
+	for {
+		select {
+		case <-stop:
+			return
+		case te := <-world.trackEntity:
+			uuid := te.entity.UUID()
+			e, ok := entities[uuid]
+			switch {
+			case te.remove:
+				if ok {
+					delete(entities, uuid)
+					for _, w := range e.viewers {
+						queuePackets(w, e.despawn)
+					}
+				}
+				continue
+			case !ok && !te.add:
+				continue
+			case !ok:
+				kind := TrackOther
+				if t, ok := te.entity.(Trackable); ok {
+					kind = t.TrackingKind()
+				}
+				e = &trackedEntity{
+					entity:        te.entity,
+					trackingRange: trackingRanges[kind],
+					despawn:       te.despawn,
+					viewers:       map[string]Watcher{},
+				}
+				entities[uuid] = e
+			}
+			e.x, e.z = te.X, te.Z
+			if te.spawn != nil {
+				e.spawn = te.spawn
+			}
+			for _, v := range viewers {
+				e.update(v)
+			}
+		case tv := <-world.trackViewer:
+			uuid := tv.watcher.UUID()
+			if tv.remove {
+				delete(viewers, uuid)
+				for _, e := range entities {
+					if w, ok := e.viewers[uuid]; ok {
+						delete(e.viewers, uuid)
+						queuePackets(w, e.despawn)
+					}
+				}
+				continue
+			}
+			v, ok := viewers[uuid]
+			if !ok {
+				v = &trackedViewer{}
+				viewers[uuid] = v
+			}
+			v.watcher = tv.watcher
+			v.x, v.z, v.distance = tv.X, tv.Z, float64(tv.distance*16)
+			for _, e := range entities {
+				e.update(v)
+			}
+		case ep := <-world.entityPacket:
+			if e, ok := entities[ep.uuid]; ok {
+				for _, w := range e.viewers {
+					w.QueuePacket(ep.packet)
+				}
+			}
+		}
+	}
+}
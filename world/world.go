@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"compress/zlib"
 	"github.com/NetherrackDev/netherrack/protocol"
+	"sync/atomic"
 	"time"
 )
 
@@ -42,15 +43,21 @@ type World struct {
 
 	loadedChunks map[uint64]*Chunk
 
-	joinChunk       chan joinChunk
-	leaveChunk      chan joinChunk
-	entityChunk     chan entityChunk
-	placeBlock      chan blockChange
-	getBlock        chan blockGet
-	light           chan lightEvent
-	chunkPacket     chan chunkPacket
-	updateSpawnData chan packetUpdate
-	timeOfDay       chan chan int64
+	joinChunk   chan joinChunk
+	leaveChunk  chan joinChunk
+	entityChunk chan entityChunk
+	placeBlock  chan blockChange
+	getBlock    chan blockGet
+	getHeight   chan heightGet
+	light       chan lightEvent
+	chunkPacket chan chunkPacket
+	timeOfDay   chan chan int64
+	spawn       chan spawnRequest
+
+	//Handled by the entity tracker's goroutine
+	trackEntity  chan trackEntity
+	trackViewer  chan trackViewer
+	entityPacket chan entityPacket
 
 	//The limiters were added because trying to send/save all the chunks
 	//at once caused large amounts of memory usage
@@ -58,10 +65,16 @@ type World struct {
 	SaveLimiter  chan struct{}
 	RequestClose chan *Chunk
 
+	//Accessed atomically
+	viewDistance int32
+
 	worldData struct {
 		Dimension  Dimension
 		AgeOfWorld int64
 		TimeOfDay  int64
+		//Where players spawn, only valid once SpawnSet is true
+		SpawnX, SpawnY, SpawnZ int32
+		SpawnSet               bool
 	}
 }
 
@@ -82,19 +95,27 @@ func (world *World) init() {
 
 	world.placeBlock = make(chan blockChange, 1000)
 	world.getBlock = make(chan blockGet, 1000)
+	world.getHeight = make(chan heightGet, 100)
 	world.light = make(chan lightEvent, 1000)
 
 	world.chunkPacket = make(chan chunkPacket, 1000)
 	world.entityChunk = make(chan entityChunk, 500)
 	world.RequestClose = make(chan *Chunk, 20)
-	world.updateSpawnData = make(chan packetUpdate, 200)
 	world.timeOfDay = make(chan chan int64, 100)
+	world.spawn = make(chan spawnRequest, 100)
+
+	world.trackEntity = make(chan trackEntity, 500)
+	world.trackViewer = make(chan trackViewer, 100)
+	world.entityPacket = make(chan entityPacket, 1000)
 }
 
 func (world *World) run() {
 	var done chan bool
 	defer func() { done <- true }()
 	defer world.system.Close()
+	stopTracker := make(chan struct{})
+	go world.runTracker(stopTracker)
+	defer close(stopTracker)
 	world.generator.Load(world)
 	world.loadedChunks = make(map[uint64]*Chunk)
 	world.SendLimiter = make(chan cachedCompressor, 20)
@@ -135,6 +156,14 @@ func (world *World) run() {
 			}
 		case ret := <-world.timeOfDay:
 			ret <- world.worldData.TimeOfDay
+		case req := <-world.spawn:
+			d := &world.worldData
+			if req.Set {
+				d.SpawnX, d.SpawnY, d.SpawnZ = int32(req.Pos[0]), int32(req.Pos[1]), int32(req.Pos[2])
+				d.SpawnSet = true
+			} else {
+				req.Ret <- [3]int{int(d.SpawnX), int(d.SpawnY), int(d.SpawnZ)}
+			}
 		case jc := <-world.joinChunk:
 			world.chunk(jc.x, jc.z).Join(jc.watcher)
 		case lc := <-world.leaveChunk:
@@ -145,7 +174,14 @@ func (world *World) run() {
 			world.chunk(cx, cz).blockPlace <- bp
 		case bg := <-world.getBlock:
 			cx, cz := bg.X>>4, bg.Z>>4
+			if _, ok := world.loadedChunks[chunkKey(cx, cz)]; bg.Loaded && !ok {
+				close(bg.Ret)
+				continue
+			}
 			world.chunk(cx, cz).blockGet <- bg
+		case hg := <-world.getHeight:
+			cx, cz := hg.X>>4, hg.Z>>4
+			world.chunk(cx, cz).heightGet <- hg
 		case l := <-world.light:
 			cx, cz := l.X>>4, l.Z>>4
 			world.chunk(cx, cz).light <- l
@@ -158,8 +194,6 @@ func (world *World) run() {
 			if chunk.Close() {
 				delete(world.loadedChunks, chunkKey(chunk.X, chunk.Z))
 			}
-		case pu := <-world.updateSpawnData:
-			world.chunk(pu.X, pu.Z).entitySpawnUpdate <- pu
 		}
 	}
 }
@@ -170,38 +204,33 @@ func (world *World) TimeOfDay() int64 {
 	return <-ret
 }
 
-type packetUpdate struct {
-	entity  Entity
-	X, Z    int
-	spawn   bool
-	packets []protocol.Packet
+type entityChunk struct {
+	Add    bool
+	Unload bool
+	X, Z   int
+	Entity Entity
+	//What is saved for the entity
+	Saved Entity
 }
 
-func (world *World) UpdateSpawnData(x, z int, entity Entity, spawn bool, packets []protocol.Packet) {
-	world.updateSpawnData <- packetUpdate{
-		X: z, Z: z,
-		entity:  entity,
-		spawn:   spawn,
-		packets: packets,
+//Returns what should be saved for the entity
+func savedEntity(entity Entity) Entity {
+	if s, ok := entity.(Snapshotter); ok {
+		return s.Snapshot()
 	}
+	return entity
 }
 
-type entityChunk struct {
-	Add     bool
-	X, Z    int
-	Entity  Entity
-	Spawn   []protocol.Packet
-	Despawn []protocol.Packet
-}
-
-func (world *World) AddEntity(x, z int, entity Entity, spawn []protocol.Packet, despawn []protocol.Packet) {
+//Adds the entity to the chunk, saveable entities are saved with the
+//chunk. Adding an entity again updates its saved copy. Use TrackEntity
+//to show the entity to players.
+func (world *World) AddEntity(x, z int, entity Entity) {
 	world.entityChunk <- entityChunk{
-		Add:     true,
-		X:       x,
-		Z:       z,
-		Entity:  entity,
-		Spawn:   spawn,
-		Despawn: despawn,
+		Add:    true,
+		X:      x,
+		Z:      z,
+		Entity: entity,
+		Saved:  savedEntity(entity),
 	}
 }
 
@@ -215,6 +244,25 @@ func (world *World) RemoveEntity(x, z int, entity Entity) {
 
 }
 
+//Removes the entity from the chunk but keeps it saved so it is loaded
+//with the chunk again. Used by entities once they have stopped after
+//being asked to unload.
+func (world *World) UnloadEntity(x, z int, entity Entity) {
+	world.entityChunk <- entityChunk{
+		Unload: true,
+		X:      x,
+		Z:      z,
+		Entity: entity,
+		Saved:  savedEntity(entity),
+	}
+}
+
+//Moves the entity from one chunk to another
+func (world *World) MoveEntity(fromX, fromZ, toX, toZ int, entity Entity) {
+	world.RemoveEntity(fromX, fromZ, entity)
+	world.AddEntity(toX, toZ, entity)
+}
+
 type blockChange struct {
 	X, Y, Z     int
 	Block, Data byte
@@ -283,18 +331,34 @@ func (world *World) SetBlock(x, y, z int, block, data byte) {
 type blockGet struct {
 	X, Y, Z int
 	Ret     chan [2]byte
+	//Only get the block if its chunk is loaded, Ret is closed if it isn't
+	Loaded bool
 }
 
 //Gets the block and data at the location
 func (world *World) Block(x, y, z int) (block, data byte) {
 	ret := make(chan [2]byte, 1)
 	world.getBlock <- blockGet{
-		x, y, z, ret,
+		x, y, z, ret, false,
 	}
 	d := <-ret
 	return d[0], d[1]
 }
 
+//Gets the block and data at the location if the chunk it is in is
+//loaded. Unlike Block this never loads the chunk, ok is false if it
+//isn't loaded.
+func (world *World) LoadedBlock(x, y, z int) (block, data byte, ok bool) {
+	ret := make(chan [2]byte, 1)
+	world.getBlock <- blockGet{
+		X: x, Y: y, Z: z,
+		Ret:    ret,
+		Loaded: true,
+	}
+	d, ok := <-ret
+	return d[0], d[1], ok
+}
+
 type joinChunk struct {
 	x, z    int
 	watcher Watcher
@@ -338,6 +402,19 @@ func (world *World) chunk(x, z int) *Chunk {
 	return chunk
 }
 
+//Returns the radius in chunks players in the world can see, 0 if the
+//server's view distance is used
+func (world *World) ViewDistance() int {
+	return int(atomic.LoadInt32(&world.viewDistance))
+}
+
+//Changes the radius in chunks players in the world can see. 0 uses
+//the server's view distance. Players pick up the change on their next
+//tick.
+func (world *World) SetViewDistance(distance int) {
+	atomic.StoreInt32(&world.viewDistance, int32(distance))
+}
+
 //Returns the worlds dimension
 func (world *World) Dimension() Dimension {
 	return world.worldData.Dimension